@@ -12,11 +12,25 @@ import (
 	"github.com/russross/blackfriday"
 )
 
+// Register the Streamer, so that it can be used by name from pipeline
+// files and is listed by `muta --plugins`.
+func init() {
+	muta.Register("markdown", "Render Markdown files to HTML",
+		MarkdownOpts{}, func(o MarkdownOpts) muta.Streamer {
+			return &MarkdownStreamer{Opts: o}
+		})
+}
+
 func Markdown() muta.Streamer {
 	return &MarkdownStreamer{}
 }
 
+type MarkdownOpts struct {
+	Common bool `help:"Use the common Markdown extensions"`
+}
+
 type MarkdownStreamer struct {
+	Opts MarkdownOpts
 }
 
 // The Next() method is the (only) workhorse of a Streamer. The Stream
@@ -57,7 +71,12 @@ func (s *MarkdownStreamer) Next(fi muta.FileInfo, rc io.ReadCloser) (
 	))
 
 	// Use Blackfriday to create our Markdown
-	html := blackfriday.MarkdownBasic(markdown)
+	var html []byte
+	if s.Opts.Common {
+		html = blackfriday.MarkdownCommon(markdown)
+	} else {
+		html = blackfriday.MarkdownBasic(markdown)
+	}
 
 	// ByteCloser() is a muta utility function that takes a byte array, and
 	// returns a fake ReadCloser. This is needed to satisfy the Streamer
@@ -0,0 +1,22 @@
+//
+// # Muta Gofile
+//
+package main
+
+import (
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mplugin"
+)
+
+func main() {
+	muta.Task("upper", func() muta.Stream {
+		// The plugin runs as a separate process, and could be written in
+		// any language.
+		return muta.Src("./*.txt").
+			Pipe(mplugin.NewExecPlugin("./upper-plugin")).
+			Pipe(muta.Dest("./build"))
+	})
+
+	muta.Task("default", "upper")
+	muta.Te()
+}
@@ -0,0 +1,42 @@
+//
+// # Upper Plugin
+//
+// A plugin which uppercases the content of text files. Build it with
+// `go build -o upper-plugin ./upper`, then run `muta` in the parent
+// directory.
+//
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mplugin"
+	"github.com/leeola/muta/mutil"
+)
+
+func Upper() muta.Streamer {
+	return muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+		muta.FileInfo, io.ReadCloser, error) {
+		// Leave everything but text files alone
+		if fi == nil || filepath.Ext(fi.Name()) != ".txt" {
+			return fi, rc, nil
+		}
+
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return fi, nil, err
+		}
+		return fi, mutil.ByteCloser(bytes.ToUpper(b)), nil
+	})
+}
+
+func main() {
+	// Serve the Streamer on stdin and stdout. Anything logged must go to
+	// stderr, as stdout is used by the plugin protocol.
+	mplugin.Main("upper", Upper())
+}
@@ -0,0 +1,185 @@
+package muta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// A Command is a task handler which runs an external command. For
+// example:
+//
+//	muta.Task("test", muta.Command{
+//		Args:    []string{"go", "test", "./..."},
+//		Env:     []string{"CGO_ENABLED=0"},
+//		Timeout: 5 * time.Minute,
+//	})
+//
+// Every line the command writes to stdout is logged at the info level,
+// and every line of stderr at the warn level, tagged with the name of
+// the task. The task fails if the command exits with a non-zero code, or
+// runs for longer than the Timeout. Interrupting the Tasker kills the
+// command, and the processes it started.
+type Command struct {
+	// The command and its arguments
+	Args []string `yaml:"args"`
+
+	// Additional environment variables, in the form of `KEY=value`,
+	// added to the environment of muta.
+	Env []string `yaml:"env"`
+
+	// The working directory, defaulting to that of muta.
+	Dir string `yaml:"dir"`
+
+	// If not zero, the command is killed after this long.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Cmd returns a Command for the given command and arguments.
+func Cmd(args ...string) Command {
+	return Command{Args: args}
+}
+
+func (c Command) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+// CommandError is returned when the Command of a task fails.
+type CommandError struct {
+	Command  string
+	TimedOut bool
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("Command \"%s\" timed out: %s", e.Command, e.Err)
+	}
+	return fmt.Sprintf("Command \"%s\" failed: %s", e.Command, e.Err)
+}
+
+// How long to wait for the output of a Command to close once it has
+// exited or been killed, in case a process it started holds it open.
+const commandWaitDelay = time.Second
+
+// The longest line of a Command's output that is logged as one
+// message. Longer lines are logged in parts.
+const maxCommandLine = 64 * 1024
+
+// runCommand runs the Command of the task, logging its output.
+func (tr *Tasker) runCommand(tn string, c Command) error {
+	if len(c.Args) == 0 {
+		return &CommandError{Err: errors.New("no command given")}
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	// Kill anything the command started too, and don't wait forever for
+	// them to close the output.
+	killProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+
+	tags := []string{tn}
+	stdout := &lineLogger{log: tr.Logger.Info, tags: tags}
+	stderr := &lineLogger{log: tr.Logger.Warn, tags: tags}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	tr.Logger.Debug(tags, "Running", c.String())
+	if tr.isInterrupted() {
+		return ErrInterrupted
+	}
+	err := cmd.Start()
+	if err == nil {
+		tr.addCommand(cmd)
+		err = cmd.Wait()
+		tr.removeCommand(cmd)
+	}
+	stdout.Flush()
+	stderr.Flush()
+
+	if err != nil && tr.isInterrupted() {
+		return ErrInterrupted
+	}
+	if err != nil {
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		if timedOut {
+			err = errors.New(fmt.Sprintf("killed after %s", c.Timeout))
+		}
+		return &CommandError{Command: c.String(), TimedOut: timedOut, Err: err}
+	}
+	return nil
+}
+
+// addCommand records a started Command, to be killed by Interrupt(). If
+// the Tasker was interrupted while it started, it is killed right away.
+func (tr *Tasker) addCommand(cmd *exec.Cmd) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.isInterrupted() {
+		cmd.Cancel()
+		return
+	}
+	if tr.commands == nil {
+		tr.commands = make(map[*exec.Cmd]bool)
+	}
+	tr.commands[cmd] = true
+}
+
+func (tr *Tasker) removeCommand(cmd *exec.Cmd) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	delete(tr.commands, cmd)
+}
+
+// lineLogger is an io.Writer logging every line written to it. Lines
+// longer than maxCommandLine are logged in parts, so that a command
+// can't make it buffer without limit.
+type lineLogger struct {
+	log  func([]string, ...interface{})
+	tags []string
+	buf  []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.buf = append(l.buf, p...)
+			for len(l.buf) >= maxCommandLine {
+				l.log(l.tags, string(l.buf[:maxCommandLine]))
+				l.buf = l.buf[maxCommandLine:]
+			}
+			break
+		}
+		l.buf = append(l.buf, p[:i]...)
+		l.log(l.tags, string(l.buf))
+		l.buf = l.buf[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Flush logs the last line, if it did not end with a newline.
+func (l *lineLogger) Flush() {
+	if len(l.buf) > 0 {
+		l.log(l.tags, string(l.buf))
+	}
+	l.buf = l.buf[:0]
+}
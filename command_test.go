@@ -0,0 +1,166 @@
+package muta
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeola/muta/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTaskerCommand(t *testing.T) {
+	newTasker := func(b *bytes.Buffer) *Tasker {
+		ta := NewTasker()
+		ta.Logger = logging.NewLogger(b)
+		return ta
+	}
+
+	Convey("Should add a Command task", t, func() {
+		ta := NewTasker()
+		err := ta.Task("a", Cmd("echo", "hi"))
+		So(err, ShouldBeNil)
+		So(ta.Tasks["a"].Command, ShouldResemble, &Command{
+			Args: []string{"echo", "hi"},
+		})
+		So(ta.Tasks["a"].handlerName(), ShouldEqual, "command")
+
+		c := &Command{Args: []string{"true"}}
+		err = ta.Task("b", "a", c)
+		So(err, ShouldBeNil)
+		So(ta.Tasks["b"].Command, ShouldEqual, c)
+		So(ta.Tasks["b"].Dependencies, ShouldResemble, []string{"a"})
+	})
+
+	Convey("Should log stdout and stderr tagged with the task", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("say", Cmd("sh", "-c", "echo out; echo err >&2"))
+		err := ta.RunTask("say")
+		So(err, ShouldBeNil)
+		So(b.String(), ShouldContainSubstring, "[say] out\n")
+		So(b.String(), ShouldContainSubstring, "[say] err\n")
+	})
+
+	Convey("Should use the Env and Dir", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("env", Command{
+			Args: []string{"sh", "-c", "echo $MUTA_CMD_TEST; pwd"},
+			Env:  []string{"MUTA_CMD_TEST=foo"},
+			Dir:  os.TempDir(),
+		})
+		err := ta.RunTask("env")
+		So(err, ShouldBeNil)
+		So(b.String(), ShouldContainSubstring, "[env] foo\n")
+		So(b.String(), ShouldContainSubstring,
+			"[env] "+strings.TrimSuffix(os.TempDir(), "/")+"\n")
+	})
+
+	Convey("Should fail on a non-zero exit", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("fail", Cmd("sh", "-c", "exit 3"))
+		err := ta.RunTask("fail")
+		So(err, ShouldHaveSameTypeAs, &CommandError{})
+		So(err.Error(), ShouldContainSubstring, "exit status 3")
+		So(ExitCode(err), ShouldEqual, ExitTaskFailed)
+	})
+
+	Convey("Should fail when the command is not found", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("missing", Cmd("muta-no-such-command"))
+		err := ta.RunTask("missing")
+		So(err, ShouldHaveSameTypeAs, &CommandError{})
+	})
+
+	Convey("Should log lines longer than the Scanner limit", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("long", Command{
+			Args: []string{"sh", "-c",
+				"head -c 102400 /dev/zero | tr '\\0' x; echo; echo done"},
+			Timeout: 3 * time.Second,
+		})
+		err := ta.RunTask("long")
+		So(err, ShouldBeNil)
+		So(strings.Count(b.String(), "x"), ShouldEqual, 102400)
+		So(b.String(), ShouldContainSubstring, "[long] done\n")
+	})
+
+	Convey("Should kill the processes started by the command", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("slow", Command{
+			Args:    []string{"sh", "-c", "sleep 5; echo finished"},
+			Timeout: 50 * time.Millisecond,
+		})
+		start := time.Now()
+		err := ta.RunTask("slow")
+		So(err, ShouldHaveSameTypeAs, &CommandError{})
+		So(err.(*CommandError).TimedOut, ShouldBeTrue)
+		So(time.Since(start), ShouldBeLessThan, 4*time.Second)
+		So(b.String(), ShouldNotContainSubstring, "[slow] finished")
+	})
+
+	Convey("Should kill the command when interrupted", t, func() {
+		p := filepath.Join("_test", "tmp", "interrupted")
+		os.Remove(p)
+		defer os.Remove(p)
+
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("slow", Command{
+			// The subshell outlives sh, unless its group is killed
+			Args: []string{"sh", "-c", "(sleep 1; touch " + p + ") & wait"},
+		})
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			ta.Interrupt()
+		}()
+		start := time.Now()
+		rs, err := ta.RunTasks("slow")
+		So(err, ShouldEqual, ErrInterrupted)
+		So(rs[0].Status, ShouldEqual, TaskInterrupted)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+
+		time.Sleep(1500 * time.Millisecond)
+		_, err = os.Stat(p)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("Should kill the command after the Timeout", t, func() {
+		var b bytes.Buffer
+		ta := newTasker(&b)
+		ta.Task("slow", Command{
+			Args:    []string{"sleep", "5"},
+			Timeout: 50 * time.Millisecond,
+		})
+		start := time.Now()
+		err := ta.RunTask("slow")
+		So(err, ShouldHaveSameTypeAs, &CommandError{})
+		So(err.(*CommandError).TimedOut, ShouldBeTrue)
+		So(time.Since(start), ShouldBeLessThan, 4*time.Second)
+	})
+}
+
+func TestLineLogger(t *testing.T) {
+	Convey("Should log every line, splitting long lines", t, func() {
+		var lines []string
+		l := &lineLogger{log: func(_ []string, args ...interface{}) {
+			lines = append(lines, args[0].(string))
+		}}
+		l.Write([]byte("a\nb"))
+		l.Write([]byte("c\n\nd"))
+		l.Write(bytes.Repeat([]byte("e"), maxCommandLine+1))
+		l.Flush()
+		So(len(lines), ShouldEqual, 5)
+		So(lines[:3], ShouldResemble, []string{"a", "bc", ""})
+		So(lines[3], ShouldEqual, "d"+strings.Repeat("e", maxCommandLine-1))
+		So(lines[4], ShouldEqual, "ee")
+	})
+}
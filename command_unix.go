@@ -0,0 +1,18 @@
+//go:build !windows
+
+package muta
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts the command in its own process group, and
+// kills the whole group when the command is cancelled, so that the
+// processes it started, such as those of `sh -c`, are killed too.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,8 @@
+package muta
+
+import "os/exec"
+
+// killProcessGroup does nothing on Windows, where only the command
+// itself is killed when it is cancelled. The WaitDelay of the command
+// still stops a process it started from holding up the task.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,157 @@
+package muta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/leeola/muta/logging"
+)
+
+// The completion scripts for each supported shell. Each of them asks
+// the compiled muta.go for completions with `muta --complete`, so that
+// completion stays correct as tasks change.
+var completionScripts = map[string]string{
+	"bash": `# muta bash completion
+_muta_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($(muta --complete -- "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+        compopt -o nospace
+    fi
+}
+complete -o default -F _muta_complete muta
+`,
+	"zsh": `#compdef muta
+# muta zsh completion
+_muta() {
+    local c
+    local -a candidates
+    candidates=("${(@f)$(muta --complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for c in $candidates; do
+        if [[ $c == *= ]]; then
+            compadd -S '' -- $c
+        else
+            compadd -- $c
+        fi
+    done
+}
+compdef _muta muta
+`,
+	"fish": `# muta fish completion
+function __muta_complete
+    set -l words (commandline -opc)
+    set -e words[1]
+    muta --complete -- $words (commandline -ct) 2>/dev/null
+end
+complete -c muta -f -a '(__muta_complete)'
+`,
+}
+
+// WriteCompletion writes the completion script for the given shell,
+// which must be one of bash, zsh or fish.
+func WriteCompletion(w io.Writer, shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return errors.New(fmt.Sprintf(
+			"Unsupported shell \"%s\", expected bash, zsh or fish", shell))
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// Complete returns the completions for the last of the given words,
+// which are the words typed after `muta`. Depending on what came before
+// it, the last word is completed as a flag, a task name, a log level or
+// a Param of the preceding task.
+func (tr *Tasker) Complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	prev := words[:len(words)-1]
+
+	// Find the last task given, if any, so that its Params can be
+	// completed.
+	var t *TaskerTask
+	for _, w := range prev {
+		if wt := tr.Tasks[w]; wt != nil {
+			t = wt
+		}
+	}
+
+	var cs []string
+	switch {
+	case len(prev) > 0 && prev[len(prev)-1] == "-l":
+		cs = []string{"verbose", "debug", "info", "warn", "error"}
+
+	case len(prev) > 0 && prev[len(prev)-1] == "--log-format":
+		cs = []string{logging.TextFormat, logging.JSONFormat}
+
+	case len(prev) > 0 && prev[len(prev)-1] == "--color":
+		cs = []string{ColorAuto, ColorAlways, ColorNever}
+
+	case strings.HasPrefix(cur, "-"):
+		if t == nil {
+			cs = cliFlags()
+		} else {
+			for _, p := range t.Params {
+				cs = append(cs, "--"+p.Name)
+			}
+		}
+
+	default:
+		for _, tl := range tr.List() {
+			cs = append(cs, tl.Name)
+		}
+		if t != nil {
+			for _, p := range t.Params {
+				cs = append(cs, p.Name+"=")
+			}
+		}
+	}
+
+	var matches []string
+	for _, c := range cs {
+		if strings.HasPrefix(c, cur) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// cliFlags returns every flag listed in the Options section of the
+// usage, without their arguments.
+func cliFlags() []string {
+	var flags []string
+	for f := range cliOptions() {
+		flags = append(flags, f)
+	}
+	return flags
+}
+
+// cliOptions returns every flag listed in the Options section of the
+// usage, and whether it takes an argument.
+func cliOptions() map[string]bool {
+	opts := make(map[string]bool)
+	for _, l := range strings.Split(usageOptions, "\n") {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "-") {
+			continue
+		}
+		if i := strings.Index(l, "  "); i > -1 {
+			l = l[:i]
+		}
+		for _, f := range strings.Fields(l) {
+			var hasArg bool
+			if i := strings.Index(f, "="); i > -1 {
+				f, hasArg = f[:i], true
+			}
+			opts[strings.TrimSuffix(f, ",")] = hasArg
+		}
+	}
+	return opts
+}
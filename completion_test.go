@@ -0,0 +1,76 @@
+package muta
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	Convey("Should write a script for each supported shell", t, func() {
+		for _, shell := range []string{"bash", "zsh", "fish"} {
+			var b bytes.Buffer
+			So(WriteCompletion(&b, shell), ShouldBeNil)
+			So(b.String(), ShouldContainSubstring, "muta --complete")
+		}
+	})
+
+	Convey("Should error on unsupported shells", t, func() {
+		var b bytes.Buffer
+		So(WriteCompletion(&b, "tcsh"), ShouldNotBeNil)
+	})
+}
+
+func TestTaskerComplete(t *testing.T) {
+	ta := NewTasker()
+	ta.Task("build", func() {})
+	ta.Task("bump", func() {})
+	ta.Task("secret", Hidden(), func() {})
+	ta.Task("deploy", StringParam("env", "staging", ""),
+		BoolParam("dry-run", false, ""), func(Params) error { return nil })
+
+	Convey("Should complete task names", t, func() {
+		So(ta.Complete([]string{"b"}), ShouldResemble,
+			[]string{"build", "bump"})
+		So(ta.Complete(nil), ShouldResemble,
+			[]string{"build", "bump", "deploy"})
+	})
+
+	Convey("Should complete flags", t, func() {
+		cs := ta.Complete([]string{"--pro"})
+		So(cs, ShouldResemble, []string{"--profile"})
+	})
+
+	Convey("Should complete Params of the preceding task", t, func() {
+		So(ta.Complete([]string{"deploy", "e"}), ShouldResemble,
+			[]string{"env="})
+		So(ta.Complete([]string{"deploy", "--"}), ShouldResemble,
+			[]string{"--dry-run", "--env"})
+	})
+
+	Convey("Should complete log levels", t, func() {
+		So(ta.Complete([]string{"-l", "d"}), ShouldResemble,
+			[]string{"debug"})
+	})
+
+	Convey("Should complete log formats", t, func() {
+		So(ta.Complete([]string{"--log-format", ""}), ShouldResemble,
+			[]string{"json", "text"})
+	})
+
+	Convey("Should complete colors", t, func() {
+		So(ta.Complete([]string{"--color", "a"}), ShouldResemble,
+			[]string{"always", "auto"})
+	})
+}
+
+func TestCliFlags(t *testing.T) {
+	Convey("Should list the flags of the usage", t, func() {
+		fs := cliFlags()
+		So(fs, ShouldContain, "-l")
+		So(fs, ShouldContain, "--cpuprofile")
+		So(fs, ShouldContain, "-h")
+		So(fs, ShouldContain, "--help")
+	})
+}
@@ -1,3 +1,268 @@
 package muta
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/leeola/muta/logging"
+	"gopkg.in/yaml.v2"
+)
+
 const VERSION string = "0.0.0"
+
+// The config files looked for by LoadConfig, in order. Only the first
+// found is loaded.
+var ConfigFiles = []string{".muta.yaml", ".muta.yml", ".muta.json"}
+
+// The prefix of the environment variables read by Config.ApplyEnv
+const EnvPrefix string = "MUTA_"
+
+// The values of Config.Color
+const (
+	ColorAuto   string = "auto"
+	ColorAlways string = "always"
+	ColorNever  string = "never"
+)
+
+// Config holds the defaults of the CLI options. It is loaded from a
+// config file and the environment, and any flags given on the command
+// line override it.
+type Config struct {
+	// The log level, such as "debug", optionally followed by the levels
+	// of tags, such as "info,muta.Dest=debug"
+	LogLevel string `yaml:"log_level" json:"log_level"`
+
+	// The log format, "text" or "json"
+	LogFormat string `yaml:"log_format" json:"log_format"`
+
+	// If not empty, every message is also logged to this file, at the
+	// verbose level and regardless of the tags
+	LogFile string `yaml:"log_file,omitempty" json:"log_file,omitempty"`
+
+	// When to color the output, "auto", "always" or "never". Auto colors
+	// the output when it is a terminal.
+	Color string `yaml:"color" json:"color"`
+
+	// The logging tags to show, all tags are shown if empty
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// The number of tasks to run at once
+	Jobs int `yaml:"jobs" json:"jobs"`
+
+	// The task to run when none are given
+	Default string `yaml:"default" json:"default"`
+
+	// If not empty, a JSON Report of every run is written to this path
+	Report string `yaml:"report,omitempty" json:"report,omitempty"`
+}
+
+// The Config used for any values not otherwise configured.
+func DefaultConfig() Config {
+	return Config{
+		LogLevel:  "info",
+		LogFormat: logging.TextFormat,
+		Color:     ColorAuto,
+		Jobs:      1,
+		Default:   "default",
+	}
+}
+
+// ConfigError is returned when a config file or environment variable
+// is invalid.
+type ConfigError struct {
+	// The config file or environment variable
+	Source string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Invalid config '%s': %s", e.Source, e.Err)
+}
+
+// LoadConfig loads the first of the ConfigFiles found in dir. If none
+// exist, an empty Config is returned.
+func LoadConfig(dir string) (Config, error) {
+	for _, n := range ConfigFiles {
+		p := filepath.Join(dir, n)
+		b, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Config{}, err
+		}
+		return ParseConfig(p, b)
+	}
+	return Config{}, nil
+}
+
+// ParseConfig parses the content of the named config file, as JSON if
+// the name has a .json extension and as YAML otherwise.
+func ParseConfig(name string, b []byte) (Config, error) {
+	var c Config
+	var err error
+	if filepath.Ext(name) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&c)
+	} else {
+		err = yaml.UnmarshalStrict(b, &c)
+	}
+	if err == nil {
+		err = c.check()
+	}
+	if err != nil {
+		return Config{}, &ConfigError{name, err}
+	}
+	return c, nil
+}
+
+// ApplyEnv returns a copy of the Config, overridden by any MUTA_*
+// variables in environ, which is in the form of os.Environ(). The
+// variables are:
+//
+//	MUTA_LOG_LEVEL  The log level
+//	MUTA_LOG_FORMAT The log format, text or json
+//	MUTA_LOG_FILE   The file to write the full log to
+//	MUTA_COLOR      When to color the output, auto, always or never
+//	MUTA_TAGS       A comma separated list of logging tags
+//	MUTA_JOBS       The number of tasks to run at once
+//	MUTA_DEFAULT    The task to run when none are given
+//	MUTA_REPORT     The path to write a JSON Report to
+func (c Config) ApplyEnv(environ []string) (Config, error) {
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		k, v := kv[:i], kv[i+1:]
+
+		switch strings.TrimPrefix(k, EnvPrefix) {
+		case "LOG_LEVEL":
+			c.LogLevel = v
+			if err := c.check(); err != nil {
+				return c, &ConfigError{k, err}
+			}
+		case "LOG_FORMAT":
+			c.LogFormat = v
+			if err := c.check(); err != nil {
+				return c, &ConfigError{k, err}
+			}
+		case "LOG_FILE":
+			c.LogFile = v
+		case "COLOR":
+			c.Color = v
+			if err := c.check(); err != nil {
+				return c, &ConfigError{k, err}
+			}
+		case "TAGS":
+			c.Tags = splitTags(v)
+		case "JOBS":
+			j, err := strconv.Atoi(v)
+			if err == nil {
+				c.Jobs = j
+				err = c.check()
+			}
+			if err != nil {
+				return c, &ConfigError{k, err}
+			}
+		case "DEFAULT":
+			c.Default = v
+		case "REPORT":
+			c.Report = v
+		}
+	}
+	return c, nil
+}
+
+// Merge returns a copy of the Config, with every non-zero field of o
+// overriding it.
+func (c Config) Merge(o Config) Config {
+	if o.LogLevel != "" {
+		c.LogLevel = o.LogLevel
+	}
+	if o.LogFormat != "" {
+		c.LogFormat = o.LogFormat
+	}
+	if o.LogFile != "" {
+		c.LogFile = o.LogFile
+	}
+	if o.Color != "" {
+		c.Color = o.Color
+	}
+	if o.Tags != nil {
+		c.Tags = o.Tags
+	}
+	if o.Jobs != 0 {
+		c.Jobs = o.Jobs
+	}
+	if o.Default != "" {
+		c.Default = o.Default
+	}
+	if o.Report != "" {
+		c.Report = o.Report
+	}
+	return c
+}
+
+// WriteYAML writes the Config in the .muta.yaml format.
+func (c Config) WriteYAML(w io.Writer) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// WriteJSON writes the Config in the .muta.json format.
+func (c Config) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func (c Config) check() error {
+	if c.Jobs < 0 {
+		return errors.New(fmt.Sprintf("jobs must be positive, got %d",
+			c.Jobs))
+	}
+	if _, _, err := logging.ParseLevels(c.LogLevel); err != nil {
+		return err
+	}
+	if _, err := logging.FormatterFromString(c.LogFormat); err != nil {
+		return err
+	}
+	switch c.Color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		return errors.New(fmt.Sprintf(
+			"color must be auto, always or never, got '%s'", c.Color))
+	}
+	return nil
+}
+
+// Split a comma separated list of tags, ignoring empty tags.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
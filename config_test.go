@@ -0,0 +1,143 @@
+package muta
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseConfig(t *testing.T) {
+	Convey("Should parse YAML", t, func() {
+		c, err := ParseConfig(".muta.yaml", []byte(
+			"log_level: debug\ntags: [foo, bar]\njobs: 4\n"+
+				"default: build\nreport: report.json\n"))
+		So(err, ShouldBeNil)
+		So(c, ShouldResemble, Config{
+			LogLevel: "debug",
+			Tags:     []string{"foo", "bar"},
+			Jobs:     4,
+			Default:  "build",
+			Report:   "report.json",
+		})
+	})
+
+	Convey("Should parse JSON", t, func() {
+		c, err := ParseConfig(".muta.json", []byte(
+			`{"log_level": "warn", "jobs": 2}`))
+		So(err, ShouldBeNil)
+		So(c, ShouldResemble, Config{LogLevel: "warn", Jobs: 2})
+	})
+
+	Convey("Should return a ConfigError for invalid configs", t, func() {
+		_, err := ParseConfig(".muta.yaml", []byte("foo: bar\n"))
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+		So(ExitCode(err), ShouldEqual, ExitValidation)
+
+		_, err = ParseConfig(".muta.json", []byte(`{"foo": "bar"}`))
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = ParseConfig(".muta.yaml", []byte("jobs: -1\n"))
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = ParseConfig(".muta.yaml", []byte("log_format: xml\n"))
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = ParseConfig(".muta.yaml", []byte("log_level: info,a=foo\n"))
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	Convey("Should load the first config file found", t, func() {
+		dir, err := ioutil.TempDir("", "muta-config")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		c, err := LoadConfig(dir)
+		So(err, ShouldBeNil)
+		So(c, ShouldResemble, Config{})
+
+		ioutil.WriteFile(filepath.Join(dir, ".muta.json"),
+			[]byte(`{"jobs": 2}`), 0644)
+		c, err = LoadConfig(dir)
+		So(err, ShouldBeNil)
+		So(c.Jobs, ShouldEqual, 2)
+
+		ioutil.WriteFile(filepath.Join(dir, ".muta.yaml"),
+			[]byte("jobs: 3\n"), 0644)
+		c, err = LoadConfig(dir)
+		So(err, ShouldBeNil)
+		So(c.Jobs, ShouldEqual, 3)
+	})
+}
+
+func TestConfigApplyEnv(t *testing.T) {
+	Convey("Should override the Config with MUTA_ variables", t, func() {
+		c, err := DefaultConfig().ApplyEnv([]string{
+			"HOME=/foo",
+			"MUTA_LOG_LEVEL=debug",
+			"MUTA_LOG_FORMAT=json",
+			"MUTA_COLOR=never",
+			"MUTA_LOG_FILE=build.log",
+			"MUTA_TAGS=foo, bar",
+			"MUTA_JOBS=3",
+			"MUTA_DEFAULT=build",
+			"MUTA_REPORT=r.json",
+		})
+		So(err, ShouldBeNil)
+		So(c, ShouldResemble, Config{
+			LogLevel:  "debug",
+			LogFormat: "json",
+			LogFile:   "build.log",
+			Color:     "never",
+			Tags:      []string{"foo", "bar"},
+			Jobs:      3,
+			Default:   "build",
+			Report:    "r.json",
+		})
+	})
+
+	Convey("Should return a ConfigError for invalid jobs", t, func() {
+		_, err := DefaultConfig().ApplyEnv([]string{"MUTA_JOBS=foo"})
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = DefaultConfig().ApplyEnv([]string{"MUTA_LOG_FORMAT=xml"})
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = DefaultConfig().ApplyEnv([]string{"MUTA_LOG_LEVEL=foo"})
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+
+		_, err = DefaultConfig().ApplyEnv([]string{"MUTA_COLOR=foo"})
+		So(err, ShouldHaveSameTypeAs, &ConfigError{})
+	})
+}
+
+func TestConfigMerge(t *testing.T) {
+	Convey("Should only override with set values", t, func() {
+		c := DefaultConfig().Merge(Config{Jobs: 2, Tags: []string{}})
+		So(c, ShouldResemble, Config{
+			LogLevel:  "info",
+			LogFormat: "text",
+			Color:     "auto",
+			Tags:      []string{},
+			Jobs:      2,
+			Default:   "default",
+		})
+	})
+}
+
+func TestConfigWriteYAML(t *testing.T) {
+	Convey("Should write a config that can be parsed", t, func() {
+		var b bytes.Buffer
+		c := Config{LogLevel: "debug", Tags: []string{"foo"}, Jobs: 2,
+			Default: "build"}
+		So(c.WriteYAML(&b), ShouldBeNil)
+		parsed, err := ParseConfig(".muta.yaml", b.Bytes())
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, c)
+	})
+}
@@ -0,0 +1,66 @@
+package muta
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Description is a static view of a Streamer, describing its name, its
+// options, and any Streamers nested within it.
+type Description struct {
+	Name      string                 `json:"name"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Streamers []Description          `json:"streamers,omitempty"`
+}
+
+// Describer is an optional interface for Streamers, allowing them to
+// describe themselves. This is used when introspecting a Stream, such
+// as with `muta --graph`.
+type Describer interface {
+	Describe() Description
+}
+
+// Describe returns the Description of the given Streamer. If the
+// Streamer does not implement Describer, the Go type of the Streamer
+// is used as the name.
+func Describe(sr Streamer) Description {
+	if d, ok := sr.(Describer); ok {
+		return d.Describe()
+	}
+	return Description{Name: fmt.Sprintf("%T", sr)}
+}
+
+// Describe returns the full nested Description of this Stream and all
+// of the Streamers contained within it.
+func (s Stream) Describe() Description {
+	d := Description{Name: "muta.Stream"}
+	for _, sr := range s {
+		d.Streamers = append(d.Streamers, Describe(sr))
+	}
+	return d
+}
+
+// OptionsString returns the options of the Description as a sorted,
+// space separated list of `key=value` pairs.
+func (d Description) OptionsString() string {
+	keys := make([]string, 0, len(d.Options))
+	for k := range d.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	opts := make([]string, len(keys))
+	for i, k := range keys {
+		opts[i] = fmt.Sprintf("%s=%v", k, d.Options[k])
+	}
+	return strings.Join(opts, " ")
+}
+
+// String returns the Name of the Description, followed by its options.
+func (d Description) String() string {
+	if len(d.Options) == 0 {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.OptionsString())
+}
@@ -0,0 +1,42 @@
+package muta
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDescribe(t *testing.T) {
+	Convey("Should use the Describer interface", t, func() {
+		d := Describe(&DestStreamer{Destination: "build"})
+		So(d.Name, ShouldEqual, destPluginName)
+		So(d.Options["Destination"], ShouldEqual, "build")
+	})
+
+	Convey("Should fall back to the type name", t, func() {
+		d := Describe(&MockStreamer{})
+		So(d.Name, ShouldEqual, "*muta.MockStreamer")
+		So(d.Options, ShouldBeNil)
+	})
+}
+
+func TestStreamDescribe(t *testing.T) {
+	Convey("Should describe nested Streams", t, func() {
+		s := Stream{&MockStreamer{}, Stream{&SrcStreamer{Base: "foo"}}}
+		d := s.Describe()
+		So(d.Name, ShouldEqual, "muta.Stream")
+		So(len(d.Streamers), ShouldEqual, 2)
+		So(d.Streamers[1].Name, ShouldEqual, "muta.Stream")
+		So(d.Streamers[1].Streamers[0].Name, ShouldEqual, srcPluginName)
+	})
+}
+
+func TestDescriptionString(t *testing.T) {
+	Convey("Should include sorted options", t, func() {
+		d := Description{
+			Name:    "foo",
+			Options: map[string]interface{}{"b": 2, "a": 1},
+		}
+		So(d.String(), ShouldEqual, "foo (a=1 b=2)")
+	})
+}
@@ -6,21 +6,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-
-	"github.com/leeola/muta/logging"
 )
 
 const destPluginName string = "muta.Dest"
 
 type DestOpts struct {
-	// Remove the entire destination directory before writing anything.
-	//
-	// Note: This is entirely handled by the DestWithOpts() func, not
-	// the Stream itself.
-	//
-	// TODO: Move this option to the DestWithOpts() func, or possibly
-	// move the Clean functionality into the Streamer object itself,
-	// so that it makes sense to keep this Option here.
+	// Remove the entire destination directory before writing anything,
+	// when the Stream starts.
 	Clean bool
 
 	// Overwrite the contents of any encountered files. If false, an error
@@ -43,23 +35,12 @@ func Dest(d string) Streamer {
 }
 
 // Return a DestStreamer{}, with the given options. If DestOpts.Clean
-// is true, remove the entire Destination directory before creating
-// the Streamer.
+// is true, the entire Destination directory is removed when the Stream
+// starts.
+//
+// Nothing is touched on disk until the Stream starts, so that Streams
+// can be built only to describe them, as with `muta --graph`.
 func DestWithOpts(d string, opts DestOpts) Streamer {
-	if opts.Clean {
-		err := os.RemoveAll(d)
-		if err != nil {
-			return NewErrorStreamer(fmt.Sprintf("%s: %s",
-				destPluginName, err.Error()))
-		}
-	}
-
-	// Make the destination if needed
-	if err := os.MkdirAll(d, 0755); err != nil {
-		return NewErrorStreamer(fmt.Sprintf("%s: %s",
-			destPluginName, err.Error()))
-	}
-
 	return &DestStreamer{
 		Destination: d,
 		Opts:        opts,
@@ -69,11 +50,66 @@ func DestWithOpts(d string, opts DestOpts) Streamer {
 type DestStreamer struct {
 	Destination string
 	Opts        DestOpts
+
+	// The number of files and bytes written so far
+	written      int
+	bytesWritten int64
+
+	// Whether the Destination has been cleaned and created
+	prepared bool
+
+	StreamLogger
+}
+
+// DestStats are the number of files and bytes written by a
+// DestStreamer.
+type DestStats struct {
+	Destination string `json:"destination"`
+	Files       int    `json:"files"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// Stats returns the number of files and bytes this DestStreamer has
+// written.
+func (s *DestStreamer) Stats() DestStats {
+	return DestStats{
+		Destination: s.Destination,
+		Files:       s.written,
+		Bytes:       s.bytesWritten,
+	}
+}
+
+// prepare cleans the Destination if DestOpts.Clean is set, and creates
+// it if needed. It is called by Next until it succeeds.
+func (s *DestStreamer) prepare() error {
+	if s.Opts.Clean {
+		if err := os.RemoveAll(s.Destination); err != nil {
+			return errors.New(fmt.Sprintf("%s: %s", destPluginName,
+				err.Error()))
+		}
+	}
+
+	// Make the destination if needed
+	if err := os.MkdirAll(s.Destination, 0755); err != nil {
+		return errors.New(fmt.Sprintf("%s: %s", destPluginName,
+			err.Error()))
+	}
+	s.prepared = true
+	return nil
 }
 
 func (s *DestStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 	io.ReadCloser, error) {
 
+	if !s.prepared {
+		if err := s.prepare(); err != nil {
+			if rc != nil {
+				rc.Close()
+			}
+			return nil, nil, err
+		}
+	}
+
 	if fi == nil {
 		return fi, rc, nil
 	}
@@ -89,7 +125,8 @@ func (s *DestStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 		return fi, rc, err
 	}
 
-	logging.Debug([]string{destPluginName}, "Opening", destFilepath)
+	s.Logger().With("file", destFilepath).Debug([]string{destPluginName},
+		"Opening")
 
 	var f *os.File
 	osFi, err := os.Stat(destFilepath)
@@ -152,7 +189,31 @@ func (s *DestStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 	}
 
 	// Finally, copy our reader (source) to our writer (file)
-	_, err = io.Copy(f, rc)
+	n, err := io.Copy(f, rc)
+	if err != nil {
+		// Don't leave a partial file behind
+		f.Close()
+		os.Remove(destFilepath)
+		return fi, rc, errors.New(fmt.Sprintf(
+			"%s: Unable to write '%s': %s",
+			destPluginName,
+			destFilepath,
+			err.Error(),
+		))
+	}
+	s.written++
+	s.bytesWritten += n
 
 	return fi, rc, nil
 }
+
+func (s *DestStreamer) Describe() Description {
+	return Description{
+		Name: destPluginName,
+		Options: map[string]interface{}{
+			"Destination": s.Destination,
+			"Clean":       s.Opts.Clean,
+			"Overwrite":   s.Opts.Overwrite,
+		},
+	}
+}
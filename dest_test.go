@@ -1,10 +1,13 @@
 package muta
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/leeola/muta/logging"
@@ -20,9 +23,24 @@ func TestDestWithOpts(t *testing.T) {
 
 	os.RemoveAll(filepath.Join(tmpDir, "dest"))
 
+	Convey("Should not touch the destination until the Stream starts", t,
+		func() {
+			clean := filepath.Join(tmpDir, "clean")
+			os.MkdirAll(clean, 0755)
+			defer os.RemoveAll(clean)
+			ioutil.WriteFile(filepath.Join(clean, "keep"), []byte("keep"), 0644)
+
+			DestWithOpts(clean, DestOpts{Clean: true})
+			DestWithOpts(filepath.Join(tmpDir, "dest"), DestOpts{})
+			_, err := os.Stat(filepath.Join(clean, "keep"))
+			So(err, ShouldBeNil)
+			_, err = os.Stat(filepath.Join(tmpDir, "dest"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
 	Convey("Should create the destination if needed", t, func() {
 		DestWithOpts(filepath.Join(tmpDir, "dest"), DestOpts{
-			Clean: false, Overwrite: false})
+			Clean: false, Overwrite: false}).Next(nil, nil)
 		osFi, err := os.Stat(filepath.Join(tmpDir, "dest"))
 		So(err, ShouldBeNil)
 		So(osFi.IsDir(), ShouldBeTrue)
@@ -33,7 +51,7 @@ func TestDestWithOpts(t *testing.T) {
 
 	Convey("Should remove the destination if Clean is true", t, func() {
 		DestWithOpts(filepath.Join(tmpDir, "dest"), DestOpts{
-			Clean: true, Overwrite: false})
+			Clean: true, Overwrite: false}).Next(nil, nil)
 		osFi, err := os.Stat(filepath.Join(tmpDir, "dest"))
 		So(err, ShouldBeNil)
 		So(osFi.IsDir(), ShouldBeTrue)
@@ -48,7 +66,7 @@ func TestDestWithOpts(t *testing.T) {
 
 	Convey("Should not remove the destination if Clean isnt set", t, func() {
 		DestWithOpts(filepath.Join(tmpDir, "dest"), DestOpts{
-			Clean: false, Overwrite: false})
+			Clean: false, Overwrite: false}).Next(nil, nil)
 		osFi, err := os.Stat(filepath.Join(tmpDir, "dest"))
 		So(err, ShouldBeNil)
 		So(osFi.IsDir(), ShouldBeTrue)
@@ -57,6 +75,34 @@ func TestDestWithOpts(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(osFi.IsDir(), ShouldBeFalse)
 	})
+
+	Convey("Should clean again on the next call if cleaning failed", t, func() {
+		blocker := filepath.Join(tmpDir, "blocker")
+		defer os.RemoveAll(blocker)
+		ioutil.WriteFile(blocker, []byte("blocker"), 0644)
+
+		s := DestWithOpts(filepath.Join(blocker, "dest"), DestOpts{Clean: true})
+		_, _, err := s.Next(nil, nil)
+		So(err, ShouldNotBeNil)
+
+		os.Remove(blocker)
+		os.MkdirAll(filepath.Join(blocker, "dest"), 0755)
+		ioutil.WriteFile(filepath.Join(blocker, "dest", "file"),
+			[]byte("REMOVE ME"), 0644)
+		_, _, err = s.Next(nil, nil)
+		So(err, ShouldBeNil)
+		_, err = os.Stat(filepath.Join(blocker, "dest", "file"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
+
+// errReader fails every Read with its error.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
 }
 
 func TestDestStreamerNext(t *testing.T) {
@@ -190,6 +236,27 @@ func TestDestStreamerNext(t *testing.T) {
 			filepath.Join("path", "path_file")))
 	})
 
+	os.RemoveAll(filepath.Join(tmpDir, "partial"))
+
+	Convey("Should remove a partially written file on a read error", t,
+		func() {
+			s := &DestStreamer{
+				Destination: tmpDir,
+				Opts:        DestOpts{Clean: false, Overwrite: false},
+			}
+			rc := ioutil.NopCloser(io.MultiReader(
+				strings.NewReader("partial content"),
+				&errReader{errors.New("read failed")}))
+
+			_, _, err := s.Next(NewFileInfo("partial"), rc)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "read failed")
+			_, err = os.Stat(filepath.Join(tmpDir, "partial"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+			So(s.Stats().Files, ShouldEqual, 0)
+			So(s.Stats().Bytes, ShouldEqual, 0)
+		})
+
 	Convey("Should not allow writing outside of the destination", t, nil)
 
 }
@@ -29,3 +29,10 @@ func (s ErrorStreamer) Next(FileInfo, io.ReadCloser) (FileInfo,
 
 	return nil, nil, s
 }
+
+func (s ErrorStreamer) Describe() Description {
+	return Description{
+		Name:    "muta.Error",
+		Options: map[string]interface{}{"Message": s.Message},
+	}
+}
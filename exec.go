@@ -0,0 +1,372 @@
+package muta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const execPluginName string = "muta.Exec"
+
+func init() {
+	Register("exec", "Pipe the content of files through a command",
+		execOptions{Jobs: 1}, func(o execOptions) (Streamer, error) {
+			args := strings.Fields(o.Command)
+			if len(args) == 0 {
+				return nil, errors.New("A command is required")
+			}
+			return ExecWithOpts(ExecOpts{
+				Match:  o.Match,
+				Rename: o.Rename,
+				Dir:    o.Dir,
+				Jobs:   o.Jobs,
+			}, args[0], args[1:]...), nil
+		})
+}
+
+// The options of the "exec" Streamer, for pipeline files.
+type execOptions struct {
+	Command string `help:"The command and its space separated arguments"`
+	Match   string `help:"A glob of the file names to run the command on"`
+	Rename  string `help:"A template of the new file name"`
+	Dir     string `help:"The directory to run the command in"`
+	Jobs    int    `help:"The number of commands to run at once"`
+}
+
+type ExecOpts struct {
+	// Only files with names matching this glob, such as `*.css`, are
+	// run through the command. All other files are passed on as is.
+	// Every file is matched if empty.
+	Match string
+
+	// A template of the new name of the file, such as `{{.Name}}.min`.
+	// The name is not changed if empty.
+	Rename string
+
+	// The working directory and environment of the command, defaulting
+	// to that of muta.
+	Dir string
+	Env []string
+
+	// The number of commands run at once. Above one, each file is
+	// started as it arrives, and files are passed on in order as their
+	// commands complete.
+	Jobs int
+}
+
+// Return an ExecStreamer{} for the given command and arguments, with
+// the following default options:
+//
+//	ExecOpts{
+//		Jobs: 1,
+//	}
+//
+// The arguments are templates, executed with the FileInfo of each
+// file. For example:
+//
+//	Exec("sed", "s/{{.OriginalName}}/{{.Name}}/")
+//
+// The name, path and original name and path of the file are available
+// as {{.Name}}, {{.Path}}, {{.OriginalName}} and {{.OriginalPath}}.
+func Exec(command string, args ...string) Streamer {
+	return ExecWithOpts(ExecOpts{Jobs: 1}, command, args...)
+}
+
+// Return an ExecStreamer{}, with the given options.
+func ExecWithOpts(opts ExecOpts, command string, args ...string) Streamer {
+	s := &ExecStreamer{
+		Command: command,
+		Args:    args,
+		Opts:    opts,
+	}
+
+	if _, err := filepath.Match(opts.Match, ""); err != nil {
+		return NewErrorStreamer(fmt.Sprintf("%s: Invalid match \"%s\": %s",
+			execPluginName, opts.Match, err.Error()))
+	}
+
+	for _, a := range args {
+		t, err := template.New("arg").Parse(a)
+		if err != nil {
+			return NewErrorStreamer(fmt.Sprintf("%s: %s",
+				execPluginName, err.Error()))
+		}
+		s.args = append(s.args, t)
+	}
+
+	if opts.Rename != "" {
+		t, err := template.New("rename").Parse(opts.Rename)
+		if err != nil {
+			return NewErrorStreamer(fmt.Sprintf("%s: %s",
+				execPluginName, err.Error()))
+		}
+		s.rename = t
+	}
+
+	return s
+}
+
+// An ExecStreamer runs a command for every file, with the content of
+// the file as stdin, replacing the content with the command's stdout.
+type ExecStreamer struct {
+	Command string
+	Args    []string
+	Opts    ExecOpts
+
+	args   []*template.Template
+	rename *template.Template
+
+	// The files started but not yet passed on, in order
+	queue []*execJob
+
+	StreamLogger
+}
+
+// ExecError is returned when a command fails for a file, with anything
+// the command wrote to stderr.
+type ExecError struct {
+	// The path of the file, before it was renamed
+	File    string
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *ExecError) Error() string {
+	s := fmt.Sprintf("%s: %s: %s failed: %s", execPluginName, e.File,
+		e.Command, e.Err)
+	if e.Stderr != "" {
+		s += ": " + e.Stderr
+	}
+	return s
+}
+
+// A file being run through the command.
+type execJob struct {
+	fi   FileInfo
+	rc   io.ReadCloser
+	cmd  *exec.Cmd
+	err  error
+	done chan struct{}
+}
+
+func (s *ExecStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+
+	if fi == nil || !s.match(fi) {
+		return fi, rc, nil
+	}
+
+	j := s.start(fi, rc)
+	<-j.done
+	return j.fi, j.rc, j.err
+}
+
+func (s *ExecStreamer) Emit(fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) error {
+
+	if s.Opts.Jobs <= 1 {
+		fi, rc, err := s.Next(fi, rc)
+		if err != nil || fi == nil {
+			return err
+		}
+		return emit(fi, rc)
+	}
+
+	// The input has ended, so pass on every remaining file.
+	if fi == nil {
+		return s.flush(0, emit)
+	}
+
+	// Unmatched files are queued as well, to keep the files in order.
+	var j *execJob
+	if s.match(fi) {
+		if err := s.flush(s.Opts.Jobs-1, emit); err != nil {
+			if rc != nil {
+				rc.Close()
+			}
+			return err
+		}
+		j = s.start(fi, rc)
+	} else {
+		j = &execJob{fi: fi, rc: rc, done: make(chan struct{})}
+		close(j.done)
+	}
+	s.queue = append(s.queue, j)
+
+	// Pass on any files which have already completed
+	for len(s.queue) > 0 {
+		select {
+		case <-s.queue[0].done:
+			if err := s.pop(emit); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Close kills any running commands, and closes the content of any files
+// not yet passed on.
+func (s *ExecStreamer) Close() error {
+	for _, j := range s.queue {
+		if j.cmd != nil && j.cmd.Process != nil {
+			j.cmd.Process.Kill()
+		}
+		<-j.done
+		if j.rc != nil {
+			j.rc.Close()
+		}
+	}
+	s.queue = nil
+	return nil
+}
+
+func (s *ExecStreamer) Describe() Description {
+	opts := map[string]interface{}{
+		"Command": s.Command,
+		"Args":    s.Args,
+	}
+	if s.Opts.Match != "" {
+		opts["Match"] = s.Opts.Match
+	}
+	if s.Opts.Rename != "" {
+		opts["Rename"] = s.Opts.Rename
+	}
+	if s.Opts.Jobs > 1 {
+		opts["Jobs"] = s.Opts.Jobs
+	}
+	return Description{Name: execPluginName, Options: opts}
+}
+
+// flush waits for queued files in order, passing them on, until no more
+// than n remain queued.
+func (s *ExecStreamer) flush(n int, emit EmitFunc) error {
+	for len(s.queue) > n {
+		if err := s.pop(emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pop waits for the first queued file, and passes it on.
+func (s *ExecStreamer) pop(emit EmitFunc) error {
+	j := s.queue[0]
+	<-j.done
+	s.queue = s.queue[1:]
+	if j.err != nil {
+		return j.err
+	}
+	return emit(j.fi, j.rc)
+}
+
+func (s *ExecStreamer) match(fi FileInfo) bool {
+	if s.Opts.Match == "" {
+		return true
+	}
+	ok, _ := filepath.Match(s.Opts.Match, fi.Name())
+	return ok
+}
+
+// start runs the command for the file, closing done once the command
+// has exited. The file is renamed, and its content replaced by stdout,
+// if the command succeeded.
+func (s *ExecStreamer) start(fi FileInfo, rc io.ReadCloser) *execJob {
+	j := &execJob{fi: fi, done: make(chan struct{})}
+	file := filepath.Join(fi.Path(), fi.Name())
+	fail := func(err error) *execJob {
+		if rc != nil {
+			rc.Close()
+		}
+		j.err = &ExecError{File: file, Command: s.Command, Err: err}
+		close(j.done)
+		return j
+	}
+
+	args := make([]string, len(s.args))
+	for i, t := range s.args {
+		a, err := execute(t, fi)
+		if err != nil {
+			return fail(err)
+		}
+		args[i] = a
+	}
+
+	var name string
+	if s.rename != nil {
+		var err error
+		if name, err = execute(s.rename, fi); err != nil {
+			return fail(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	j.cmd = exec.Command(s.Command, args...)
+	j.cmd.Dir = s.Opts.Dir
+	j.cmd.Env = s.Opts.Env
+	j.cmd.Stdout = &stdout
+	j.cmd.Stderr = &stderr
+	if rc != nil {
+		j.cmd.Stdin = rc
+	}
+
+	s.Logger().With("file", file).Debug([]string{execPluginName}, "Running",
+		s.Command)
+	if err := j.cmd.Start(); err != nil {
+		return fail(err)
+	}
+
+	go func() {
+		defer close(j.done)
+		err := j.cmd.Wait()
+		if rc != nil {
+			rc.Close()
+		}
+		if err != nil {
+			j.err = &ExecError{
+				File:    file,
+				Command: s.Command,
+				Stderr:  strings.TrimSpace(stderr.String()),
+				Err:     err,
+			}
+			return
+		}
+
+		if stderr.Len() > 0 {
+			s.Logger().With("file", file).Warn([]string{execPluginName},
+				strings.TrimSpace(stderr.String()))
+		}
+		if name != "" {
+			j.fi.SetName(name)
+		}
+		j.rc = &execOutput{bytes.NewReader(stdout.Bytes())}
+	}()
+	return j
+}
+
+// Execute the template with the FileInfo.
+func execute(t *template.Template, fi FileInfo) (string, error) {
+	var b bytes.Buffer
+	err := t.Execute(&b, fi)
+	return b.String(), err
+}
+
+// The stdout of a command, which can be rewound.
+type execOutput struct {
+	*bytes.Reader
+}
+
+func (o *execOutput) Close() error { return nil }
+
+func (o *execOutput) Rewind() error {
+	_, err := o.Seek(0, io.SeekStart)
+	return err
+}
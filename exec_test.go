@@ -0,0 +1,188 @@
+package muta
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Stream the files through the Streamer, returning the names and
+// contents of the output, in order.
+func execRun(sr Streamer, files ...string) ([][2]string, error) {
+	var out [][2]string
+	s := Stream{
+		&MockStreamer{Files: files},
+		sr,
+		FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (FileInfo,
+			io.ReadCloser, error) {
+			if fi == nil {
+				return nil, nil, nil
+			}
+			defer rc.Close()
+			b, err := ioutil.ReadAll(rc)
+			out = append(out, [2]string{fi.Name(), string(b)})
+			return nil, nil, err
+		}),
+	}
+	return out, s.Stream()
+}
+
+func TestExecStreamer(t *testing.T) {
+	Convey("Should replace the content with the command output", t, func() {
+		fi, rc, err := Exec("tr", "a-z", "A-Z").Next(NewFileInfo("foo"),
+			mutil.StringCloser("foo"))
+		So(err, ShouldBeNil)
+		So(fi.Name(), ShouldEqual, "foo")
+		b, _ := ioutil.ReadAll(rc)
+		So(string(b), ShouldEqual, "FOO")
+	})
+
+	Convey("Should template the arguments with the FileInfo", t, func() {
+		fi := NewFileInfo("a/foo")
+		fi.SetName("bar")
+		_, rc, err := Exec("echo",
+			"{{.Name}} {{.Path}} {{.OriginalName}} {{.OriginalPath}}").
+			Next(fi, mutil.StringCloser(""))
+		So(err, ShouldBeNil)
+		b, _ := ioutil.ReadAll(rc)
+		So(string(b), ShouldEqual, "bar a foo a\n")
+	})
+
+	Convey("Should only run matching files", t, func() {
+		s := ExecWithOpts(ExecOpts{Match: "*.txt"}, "tr", "a-z", "A-Z")
+		out, err := execRun(s, "foo.txt", "bar.md")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{
+			{"foo.txt", "FOO.TXT CONTENT"},
+			{"bar.md", "bar.md content"},
+		})
+	})
+
+	Convey("Should rename the output", t, func() {
+		s := ExecWithOpts(ExecOpts{Rename: "{{.Name}}.upper"}, "tr", "a-z", "A-Z")
+		out, err := execRun(s, "foo")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{{"foo.upper", "FOO CONTENT"}})
+	})
+
+	Convey("Should return failures with the file and stderr", t, func() {
+		_, _, err := Exec("sh", "-c", "echo oops >&2; exit 3").Next(
+			NewFileInfo("a/foo"), mutil.StringCloser(""))
+		So(err, ShouldHaveSameTypeAs, &ExecError{})
+		ee := err.(*ExecError)
+		So(ee.File, ShouldEqual, "a/foo")
+		So(ee.Stderr, ShouldEqual, "oops")
+		So(err.Error(), ShouldEqual,
+			"muta.Exec: a/foo: sh failed: exit status 3: oops")
+
+		_, _, err = Exec("muta-command-that-does-not-exist").Next(
+			NewFileInfo("foo"), mutil.StringCloser(""))
+		So(err, ShouldHaveSameTypeAs, &ExecError{})
+	})
+
+	Convey("Should return an ErrorStreamer for invalid templates", t, func() {
+		s := Exec("echo", "{{.Name")
+		So(s, ShouldHaveSameTypeAs, ErrorStreamer{})
+	})
+
+	Convey("Should be registered as a Streamer", t, func() {
+		rs, err := LookupStreamer("exec")
+		So(err, ShouldBeNil)
+		sr, err := rs.New(map[string]interface{}{
+			"command": "tr a-z A-Z",
+			"rename":  "{{.Name}}.upper",
+		})
+		So(err, ShouldBeNil)
+		out, err := execRun(sr, "foo")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{{"foo.upper", "FOO CONTENT"}})
+
+		_, err = rs.New(nil)
+		So(err, ShouldResemble, errors.New("A command is required"))
+	})
+}
+
+func TestExecStreamerJobs(t *testing.T) {
+	Convey("Should run commands at the same time, in order", t, func() {
+		s := ExecWithOpts(ExecOpts{Jobs: 4}, "sh", "-c", "sleep 0.5; cat")
+		start := time.Now()
+		out, err := execRun(s, "a", "b", "c", "d", "e")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{
+			{"a", "a content"},
+			{"b", "b content"},
+			{"c", "c content"},
+			{"d", "d content"},
+			{"e", "e content"},
+		})
+		// One at a time would take 2.5 seconds
+		So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+	})
+
+	Convey("Should keep unmatched files in order", t, func() {
+		s := ExecWithOpts(ExecOpts{Jobs: 2, Match: "*.txt"}, "tr", "a-z", "A-Z")
+		out, err := execRun(s, "a.txt", "b", "c.txt")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{
+			{"a.txt", "A.TXT CONTENT"},
+			{"b", "b content"},
+			{"c.txt", "C.TXT CONTENT"},
+		})
+	})
+
+	Convey("Should pass on files within a nested Stream", t, func() {
+		s := Stream{ExecWithOpts(ExecOpts{Jobs: 2}, "tr", "a-z", "A-Z")}
+		out, err := execRun(s, "a", "b")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, [][2]string{
+			{"a", "A CONTENT"},
+			{"b", "B CONTENT"},
+		})
+	})
+
+	Convey("Should return the first failure", t, func() {
+		s := ExecWithOpts(ExecOpts{Jobs: 2}, "sh", "-c",
+			"test {{.Name}} != b && cat")
+		_, err := execRun(s, "a", "b", "c")
+		So(err, ShouldHaveSameTypeAs, &ExecError{})
+		So(err.(*ExecError).File, ShouldEqual, "b")
+		So(s.(*ExecStreamer).Close(), ShouldBeNil)
+	})
+
+	Convey("Should kill running commands on Close", t, func() {
+		s := ExecWithOpts(ExecOpts{Jobs: 2}, "sleep", "10").(*ExecStreamer)
+		err := s.Emit(NewFileInfo("foo"), mutil.StringCloser(""),
+			func(FileInfo, io.ReadCloser) error { return nil })
+		So(err, ShouldBeNil)
+		So(len(s.queue), ShouldEqual, 1)
+
+		start := time.Now()
+		So(s.Close(), ShouldBeNil)
+		So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+		So(s.queue, ShouldBeNil)
+	})
+}
+
+func TestStreamNestedGenerate(t *testing.T) {
+	Convey("Should let every nested Streamer generate files", t, func() {
+		var names []string
+		s := Stream{
+			&MockStreamer{Files: []string{"a"}},
+			Stream{&MockStreamer{}, &MockStreamer{Files: []string{"b"}}},
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (FileInfo,
+				io.ReadCloser, error) {
+				if fi != nil {
+					names = append(names, fi.Name())
+				}
+				return fi, rc, nil
+			}),
+		}
+		So(s.Stream(), ShouldBeNil)
+		So(names, ShouldResemble, []string{"a", "b"})
+	})
+}
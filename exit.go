@@ -0,0 +1,73 @@
+package muta
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The exit codes used by Te(), so that scripts and CI can tell the
+// different kinds of failure apart.
+const (
+	ExitOK           int = 0
+	ExitTaskFailed   int = 1
+	ExitValidation   int = 2
+	ExitTaskNotFound int = 3
+	ExitInterrupted  int = 130
+)
+
+// ErrInterrupted is returned when a run is stopped by Tasker.Interrupt(),
+// such as when muta receives an interrupt signal.
+var ErrInterrupted = errors.New("Interrupted")
+
+// TaskNotFoundError is returned when a task that does not exist is
+// given to the Tasker.
+type TaskNotFoundError struct {
+	Name string
+}
+
+func (e *TaskNotFoundError) Error() string {
+	return fmt.Sprintf("Task \"%s\" does not exist.", e.Name)
+}
+
+// CycleError is returned when the dependencies of a task lead back to
+// the task itself. Path is the chain of tasks forming the cycle.
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("Circular task dependency: %s",
+		strings.Join(e.Path, " -> "))
+}
+
+// StreamerError is an error returned by a Streamer within a Stream task,
+// recording which Streamer returned it, and which file it was given.
+// The message is that of the original error.
+type StreamerError struct {
+	Streamer string
+	File     string
+	Err      error
+}
+
+func (e *StreamerError) Error() string {
+	return e.Err.Error()
+}
+
+// ExitCode returns the exit code for the given error, as returned by
+// the Tasker.
+func ExitCode(err error) int {
+	switch err.(type) {
+	case nil:
+		return ExitOK
+	case *TaskNotFoundError:
+		return ExitTaskNotFound
+	case *ParamError, *CycleError, *ConfigError,
+		*PipelineError:
+		return ExitValidation
+	}
+	if err == ErrInterrupted {
+		return ExitInterrupted
+	}
+	return ExitTaskFailed
+}
@@ -0,0 +1,78 @@
+package muta
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExitCode(t *testing.T) {
+	Convey("Should return distinct exit codes", t, func() {
+		So(ExitCode(nil), ShouldEqual, ExitOK)
+		So(ExitCode(errors.New("foo")), ShouldEqual, ExitTaskFailed)
+		So(ExitCode(&TaskNotFoundError{"a"}), ShouldEqual, ExitTaskNotFound)
+		So(ExitCode(&ParamError{}), ShouldEqual, ExitValidation)
+		So(ExitCode(&CycleError{}), ShouldEqual, ExitValidation)
+		So(ExitCode(ErrInterrupted), ShouldEqual, ExitInterrupted)
+	})
+
+	Convey("Should be returned by the Tasker", t, func() {
+		ta := NewTasker()
+		ta.Task("a", "a")
+		_, err := ta.RunTasks("b")
+		So(ExitCode(err), ShouldEqual, ExitTaskNotFound)
+		_, err = ta.RunTasks("a")
+		So(ExitCode(err), ShouldEqual, ExitValidation)
+	})
+}
+
+func TestTaskerInterrupt(t *testing.T) {
+	Convey("Should not start further tasks", t, func() {
+		ran := false
+		ta := NewTasker()
+		ta.Task("a", func() { ta.Interrupt() })
+		ta.Task("b", func() { ran = true })
+		rs, err := ta.RunTasks("a", "b")
+		So(err, ShouldEqual, ErrInterrupted)
+		So(ran, ShouldBeFalse)
+		So(rs[0].Status, ShouldEqual, TaskOK)
+		So(rs[1].Status, ShouldEqual, TaskInterrupted)
+	})
+
+	Convey("Should stop Stream tasks", t, func() {
+		ta := NewTasker()
+		ta.Task("a", func() Stream {
+			return Stream{
+				&MockStreamer{Files: []string{"foo", "bar"}},
+				FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+					FileInfo, io.ReadCloser, error) {
+					ta.Interrupt()
+					return fi, rc, nil
+				}),
+			}
+		})
+		rs, err := ta.RunTasks("a")
+		So(err, ShouldEqual, ErrInterrupted)
+		So(rs[0].Status, ShouldEqual, TaskInterrupted)
+	})
+}
+
+func TestTaskerStreamerError(t *testing.T) {
+	Convey("Should record the Streamer returning an error", t, func() {
+		ta := NewTasker()
+		ta.Task("a", func() Stream {
+			return Stream{
+				&MockStreamer{Files: []string{"foo"}},
+				&ErrorStreamer{Message: "broken"},
+			}
+		})
+		rs, err := ta.RunTasks("a")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "broken")
+		So(rs[0].Status, ShouldEqual, TaskFailed)
+		So(rs[0].Streamer, ShouldEqual, "muta.Error")
+		So(rs[0].File, ShouldEqual, "foo")
+	})
+}
@@ -1,6 +1,15 @@
 package muta
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrMultipleFiles is returned when an Emitter produces more than one
+// file, but is called through an API that can only return a single file,
+// such as Next() or Stream.NextFrom().
+var ErrMultipleFiles = errors.New(
+	"muta: Emitter produced multiple files, use Emit() instead of Next()")
 
 // A FuncStreamer is a single Function implementation of a Streamer. Best
 // used only for very simplistic Streamers that do not need to store any
@@ -15,3 +24,49 @@ func (f FuncStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 	io.ReadCloser, error) {
 	return f(fi, rc)
 }
+
+// A FuncEmitter is a single Function implementation of an Emitter, in
+// the same way that FuncStreamer is for Streamers.
+type FuncEmitter func(FileInfo, io.ReadCloser, EmitFunc) error
+
+func (f FuncEmitter) Emit(fi FileInfo, rc io.ReadCloser, emit EmitFunc) error {
+	return f(fi, rc, emit)
+}
+
+// Next satisfies the Streamer interface. If more than one file is
+// emitted, all of them are closed and ErrMultipleFiles is returned.
+func (f FuncEmitter) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	return emitOne(f, fi, rc)
+}
+
+// emitOne calls the given Emitter, returning the emitted file if
+// exactly one is emitted.
+func emitOne(e Emitter, inFi FileInfo, inRc io.ReadCloser) (
+	fi FileInfo, rc io.ReadCloser, err error) {
+
+	var fis []FileInfo
+	var rcs []io.ReadCloser
+	err = e.Emit(inFi, inRc, func(efi FileInfo, erc io.ReadCloser) error {
+		fis = append(fis, efi)
+		rcs = append(rcs, erc)
+		return nil
+	})
+
+	if len(fis) > 1 {
+		for _, erc := range rcs {
+			if erc != nil {
+				erc.Close()
+			}
+		}
+		if err == nil {
+			err = ErrMultipleFiles
+		}
+		return nil, nil, err
+	}
+
+	if len(fis) == 1 {
+		return fis[0], rcs[0], err
+	}
+	return nil, nil, err
+}
@@ -17,3 +17,35 @@ func TestFuncStream(t *testing.T) {
 		So(ok, ShouldBeTrue)
 	})
 }
+
+func TestFuncEmitter(t *testing.T) {
+	Convey("Should implement Emitter", t, func() {
+		fn := func(FileInfo, io.ReadCloser, EmitFunc) error {
+			return nil
+		}
+		var fe interface{} = FuncEmitter(fn)
+		_, ok := fe.(Emitter)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("Next should return a single emitted file", t, func() {
+		fe := FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+			emit EmitFunc) error {
+			return emit(fi, rc)
+		})
+		fi, _, err := fe.Next(NewFileInfo("foo"), nil)
+		So(err, ShouldBeNil)
+		So(fi.Name(), ShouldEqual, "foo")
+	})
+
+	Convey("Next should error on multiple emitted files", t, func() {
+		fe := FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+			emit EmitFunc) error {
+			emit(fi, rc)
+			return emit(fi, rc)
+		})
+		fi, _, err := fe.Next(NewFileInfo("foo"), nil)
+		So(fi, ShouldBeNil)
+		So(err, ShouldEqual, ErrMultipleFiles)
+	})
+}
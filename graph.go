@@ -0,0 +1,135 @@
+package muta
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TaskDescription is a static view of a single Task, its dependencies
+// and, if it is a Stream task, the Description of its Stream.
+type TaskDescription struct {
+	Name         string       `json:"name"`
+	Dependencies []string     `json:"dependencies"`
+	Handler      string       `json:"handler"`
+	Stream       *Description `json:"stream,omitempty"`
+}
+
+// Describe returns a TaskDescription for each Task in the Tasker, sorted
+// by name.
+//
+// Note that the StreamHandler of each Stream Task is called to build the
+// Stream being described, but the Stream itself is never started. So
+// Streamers should leave anything with side effects, such as Dest
+// cleaning its directory, until they are first called.
+func (tr *Tasker) Describe() []TaskDescription {
+	names := make([]string, 0, len(tr.Tasks))
+	for n := range tr.Tasks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	tds := make([]TaskDescription, len(names))
+	for i, n := range names {
+		t := tr.Tasks[n]
+		td := TaskDescription{
+			Name:         t.Name,
+			Dependencies: t.Dependencies,
+			Handler:      t.handlerName(),
+		}
+		if t.StreamHandler != nil {
+			if s := t.StreamHandler(); s != nil {
+				d := s.Describe()
+				td.Stream = &d
+			}
+		}
+		tds[i] = td
+	}
+	return tds
+}
+
+// WriteGraph writes all of the Tasks, their dependencies and their
+// Streams to the given writer, as plain indented text.
+func (tr *Tasker) WriteGraph(w io.Writer) error {
+	for _, td := range tr.Describe() {
+		fmt.Fprintf(w, "%s (%s)\n", td.Name, td.Handler)
+		if len(td.Dependencies) > 0 {
+			fmt.Fprintf(w, "  dependencies: %s\n",
+				strings.Join(td.Dependencies, ", "))
+		}
+		if td.Stream != nil {
+			writeDescription(w, *td.Stream, "  ")
+		}
+	}
+	return nil
+}
+
+func writeDescription(w io.Writer, d Description, indent string) {
+	fmt.Fprintf(w, "%s%s\n", indent, d)
+	for _, c := range d.Streamers {
+		writeDescription(w, c, indent+"  ")
+	}
+}
+
+// WriteGraphDOT writes all of the Tasks, their dependencies and their
+// Streams to the given writer, in the Graphviz DOT format.
+//
+// Dependencies are drawn as solid edges between Tasks, and each Stream
+// is drawn as a cluster of its Streamers.
+func (tr *Tasker) WriteGraphDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph muta {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, td := range tr.Describe() {
+		id := "task:" + td.Name
+		fmt.Fprintf(w, "  %q [shape=box, label=%q];\n", id, td.Name)
+		for _, d := range td.Dependencies {
+			fmt.Fprintf(w, "  %q -> %q;\n", id, "task:"+d)
+		}
+		if td.Stream != nil {
+			first, _ := writeDOTStream(w, *td.Stream, td.Name, "  ")
+			if first != "" {
+				fmt.Fprintf(w, "  %q -> %q [style=dashed];\n", id, first)
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+// writeDOTStream writes the given Stream Description as a DOT cluster,
+// returning the ids of the first and last nodes in the cluster.
+func writeDOTStream(w io.Writer, d Description, id, indent string) (
+	first, last string) {
+
+	fmt.Fprintf(w, "%ssubgraph %q {\n", indent, "cluster_"+id)
+	fmt.Fprintf(w, "%s  label=%q;\n", indent, d.Name)
+
+	for i, c := range d.Streamers {
+		cid := fmt.Sprintf("%s.%d", id, i)
+		var node, end string
+		if len(c.Streamers) > 0 {
+			node, end = writeDOTStream(w, c, cid, indent+"  ")
+		} else {
+			node, end = cid, cid
+			label := c.Name
+			if len(c.Options) > 0 {
+				label += "\n" + c.OptionsString()
+			}
+			fmt.Fprintf(w, "%s  %q [label=%q];\n", indent, node, label)
+		}
+		if node == "" {
+			continue
+		}
+		if last != "" {
+			fmt.Fprintf(w, "%s  %q -> %q;\n", indent, last, node)
+		}
+		if first == "" {
+			first = node
+		}
+		last = end
+	}
+
+	fmt.Fprintf(w, "%s}\n", indent)
+	return first, last
+}
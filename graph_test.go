@@ -0,0 +1,54 @@
+package muta
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTaskerDescribe(t *testing.T) {
+	Convey("Should describe tasks sorted by name", t, func() {
+		tr := NewTasker()
+		tr.Task("b", "a", func() {})
+		tr.Task("a", func() Stream {
+			return Stream{&MockStreamer{}}
+		})
+		tds := tr.Describe()
+		So(len(tds), ShouldEqual, 2)
+		So(tds[0].Name, ShouldEqual, "a")
+		So(tds[0].Handler, ShouldEqual, "stream")
+		So(tds[0].Stream, ShouldNotBeNil)
+		So(tds[1].Name, ShouldEqual, "b")
+		So(tds[1].Dependencies, ShouldResemble, []string{"a"})
+		So(tds[1].Stream, ShouldBeNil)
+	})
+}
+
+func TestTaskerWriteGraph(t *testing.T) {
+	tr := NewTasker()
+	tr.Task("build", "clean", func() Stream {
+		return Stream{&SrcStreamer{Base: "src"}, &DestStreamer{}}
+	})
+	tr.Task("clean", func() {})
+
+	Convey("Should render plain text", t, func() {
+		var b bytes.Buffer
+		tr.WriteGraph(&b)
+		s := b.String()
+		So(s, ShouldContainSubstring, "build (stream)")
+		So(s, ShouldContainSubstring, "dependencies: clean")
+		So(s, ShouldContainSubstring, "    muta.Src (Base=src")
+	})
+
+	Convey("Should render DOT", t, func() {
+		var b bytes.Buffer
+		tr.WriteGraphDOT(&b)
+		s := b.String()
+		So(s, ShouldStartWith, "digraph muta {")
+		So(s, ShouldContainSubstring, `"task:build" -> "task:clean";`)
+		So(s, ShouldContainSubstring, `"build.0" -> "build.1";`)
+		So(s, ShouldContainSubstring,
+			`"task:build" -> "build.0" [style=dashed];`)
+	})
+}
@@ -0,0 +1,126 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/leeola/muta/mutil"
+)
+
+// TaskListing is the summary of a single task, as shown by
+// `muta --list`.
+type TaskListing struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Dependencies []string `json:"dependencies"`
+	Params       []Param  `json:"params"`
+
+	// Default is true if the task is a dependency of the "default" task,
+	// and will be run when no task is given.
+	Default bool `json:"default"`
+}
+
+// List returns a TaskListing for every task, sorted by name. Hidden
+// tasks and the "default" task itself are not included.
+func (tr *Tasker) List() []TaskListing {
+	var defaults []string
+	if dt := tr.Tasks["default"]; dt != nil {
+		defaults = dt.Dependencies
+	}
+
+	tls := []TaskListing{}
+	for _, t := range tr.Tasks {
+		if t.Hidden || t.Name == "default" {
+			continue
+		}
+		ds := t.Dependencies
+		if ds == nil {
+			ds = []string{}
+		}
+		ps := t.Params
+		if ps == nil {
+			ps = []Param{}
+		}
+		tls = append(tls, TaskListing{
+			Name:         t.Name,
+			Description:  t.Description,
+			Dependencies: ds,
+			Params:       ps,
+			Default:      mutil.ContainsString(defaults, t.Name),
+		})
+	}
+	sort.Sort(taskListings(tls))
+	return tls
+}
+
+// WriteList writes the List() of tasks as an aligned table of names,
+// descriptions and dependencies.
+func (tr *Tasker) WriteList(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, l := range tr.listLines() {
+		fmt.Fprintln(tw, l)
+	}
+	return tw.Flush()
+}
+
+// WriteListJSON writes the List() of tasks as a JSON array, for use by
+// editors and other tools.
+func (tr *Tasker) WriteListJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tr.List())
+}
+
+// listLines returns a tab separated line for each listed task, followed
+// by an indented line for each of its Params.
+func (tr *Tasker) listLines() []string {
+	var lines []string
+	for _, tl := range tr.List() {
+		l := tl.Name + "\t" + tl.Description
+		if tl.Default {
+			l += " (default)"
+		}
+		if len(tl.Dependencies) > 0 {
+			l += "\t[" + strings.Join(tl.Dependencies, ", ") + "]"
+		}
+		lines = append(lines, l)
+
+		for _, p := range tl.Params {
+			lines = append(lines, paramLine(p))
+		}
+	}
+	return lines
+}
+
+// paramLine returns an indented, tab separated line describing the
+// Param.
+func paramLine(p Param) string {
+	return fmt.Sprintf("\t%s=<%s>\t%s [default: %v]",
+		p.Name, p.Kind, p.Help, p.Default)
+}
+
+// helpTasks returns the aligned task lines shown by `muta -h`.
+func (tr *Tasker) helpTasks() []string {
+	var b bytes.Buffer
+	tr.WriteList(&b)
+	s := strings.TrimRight(b.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return lines
+}
+
+type taskListings []TaskListing
+
+func (tls taskListings) Len() int           { return len(tls) }
+func (tls taskListings) Less(i, j int) bool { return tls[i].Name < tls[j].Name }
+func (tls taskListings) Swap(i, j int)      { tls[i], tls[j] = tls[j], tls[i] }
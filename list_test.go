@@ -0,0 +1,43 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTaskerList(t *testing.T) {
+	ta := NewTasker()
+	ta.Task("c", Desc("Does c"), "b", func() {})
+	ta.Task("b", func() {})
+	ta.Task("a", Hidden(), func() {})
+	ta.Task("default", "c")
+
+	Convey("Should list visible tasks sorted by name", t, func() {
+		tls := ta.List()
+		So(len(tls), ShouldEqual, 2)
+		So(tls[0].Name, ShouldEqual, "b")
+		So(tls[0].Default, ShouldBeFalse)
+		So(tls[1].Name, ShouldEqual, "c")
+		So(tls[1].Description, ShouldEqual, "Does c")
+		So(tls[1].Dependencies, ShouldResemble, []string{"b"})
+		So(tls[1].Default, ShouldBeTrue)
+	})
+
+	Convey("Should write a plain text list", t, func() {
+		var b bytes.Buffer
+		ta.WriteList(&b)
+		So(b.String(), ShouldContainSubstring, "Does c (default)  [b]")
+		So(b.String(), ShouldNotContainSubstring, "a ")
+	})
+
+	Convey("Should write a JSON list", t, func() {
+		var b bytes.Buffer
+		ta.WriteListJSON(&b)
+		var tls []TaskListing
+		So(json.Unmarshal(b.Bytes(), &tls), ShouldBeNil)
+		So(tls, ShouldResemble, ta.List())
+	})
+}
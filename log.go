@@ -2,11 +2,60 @@ package muta
 
 import "github.com/leeola/muta/logging"
 
-// Add two simple shortcuts for Info
+// Add two simple shortcuts for Info, logging with the Logger of the
+// DefaultTasker, so that they follow the logging flags of Te().
 func Log(t []string, args ...interface{}) {
-	logging.Info(t, args...)
+	defaultTaskerLogger().Info(t, args...)
 }
 
 func Logf(t []string, m string, args ...interface{}) {
-	logging.Infof(t, m, args...)
+	defaultTaskerLogger().Infof(t, m, args...)
+}
+
+func defaultTaskerLogger() *logging.Logger {
+	if DefaultTasker.Logger == nil {
+		return logging.DefaultLogger()
+	}
+	return DefaultTasker.Logger
+}
+
+// LoggerSetter is an optional interface for Streamers which log. Before
+// a Stream task runs, every LoggerSetter of the Stream is given the
+// Logger of the Tasker, scoped with the "task" and "streamer" fields.
+type LoggerSetter interface {
+	SetLogger(*logging.Logger)
+}
+
+// StreamLogger implements LoggerSetter, and can be embedded in a
+// Streamer to log with the Logger of the task running it. Streamers
+// used outside of a Tasker log with the default Logger of the logging
+// package.
+type StreamLogger struct {
+	logger *logging.Logger
+}
+
+func (l *StreamLogger) SetLogger(lg *logging.Logger) {
+	l.logger = lg
+}
+
+// Logger returns the Logger given to SetLogger, or the default Logger
+// if none was given.
+func (l *StreamLogger) Logger() *logging.Logger {
+	if l.logger == nil {
+		return logging.DefaultLogger()
+	}
+	return l.logger
+}
+
+// setLoggers gives every LoggerSetter of the Stream, including those
+// of nested Streams, the given Logger scoped to the Streamer.
+func (s Stream) setLoggers(l *logging.Logger) {
+	for _, sr := range s {
+		switch v := sr.(type) {
+		case Stream:
+			v.setLoggers(l)
+		case LoggerSetter:
+			v.SetLogger(l.With("streamer", Describe(sr).Name))
+		}
+	}
 }
@@ -0,0 +1,85 @@
+package muta
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leeola/muta/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type logStreamer struct {
+	StreamLogger
+}
+
+func (s *logStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	if fi != nil {
+		s.Logger().Info([]string{"log"}, fi.Name())
+	}
+	return fi, rc, nil
+}
+
+func TestLog(t *testing.T) {
+	Convey("Should log with the Logger of the DefaultTasker", t, func() {
+		var b bytes.Buffer
+		l := DefaultTasker.Logger
+		DefaultTasker.Logger = logging.NewLogger(&b)
+		defer func() { DefaultTasker.Logger = l }()
+
+		Log([]string{"foo"}, "bar")
+		Logf(nil, "%d", 1)
+		So(b.String(), ShouldEqual, "[foo] bar\n1\n")
+	})
+}
+
+func TestStreamLogger(t *testing.T) {
+	Convey("Should default to the default Logger", t, func() {
+		var l StreamLogger
+		So(l.Logger(), ShouldEqual, logging.DefaultLogger())
+	})
+
+	Convey("Should return the given Logger", t, func() {
+		var l StreamLogger
+		lg := logging.NewLogger(nil)
+		l.SetLogger(lg)
+		So(l.Logger(), ShouldEqual, lg)
+	})
+}
+
+func TestTaskerStreamLoggers(t *testing.T) {
+	src := filepath.Join("_test", "fixtures", "hello")
+
+	Convey("Should scope the Loggers of Streamers to the task", t, func() {
+		dest := filepath.Join("_test", "tmp", "log")
+		defer os.RemoveAll(dest)
+
+		var b bytes.Buffer
+		ta := NewTasker()
+		ta.Logger = logging.NewLogger(&b)
+		ta.Logger.SetLevels("error,muta.Src=debug,log=info")
+		ta.Task("a", func() Stream {
+			return Src(src).Pipe(Stream{&logStreamer{}}).Pipe(Dest(dest))
+		})
+		So(ta.RunTask("a"), ShouldBeNil)
+		So(b.String(), ShouldEqual,
+			"[muta.Src] Opening task=a streamer=muta.Src file="+src+"\n"+
+				"[log] hello task=a streamer=*muta.logStreamer\n")
+	})
+
+	Convey("Should not log to other Taskers", t, func() {
+		var b1, b2 bytes.Buffer
+		t1, t2 := NewTasker(), NewTasker()
+		t1.Logger = logging.NewLogger(&b1)
+		t2.Logger = logging.NewLogger(&b2)
+		t1.Task("a", func() Stream {
+			return Src(src).Pipe(&logStreamer{})
+		})
+		So(t1.RunTask("a"), ShouldBeNil)
+		So(b1.String(), ShouldContainSubstring, "[log] hello task=a")
+		So(b2.String(), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The names of the Formatters, as given to FormatterFromString.
+const (
+	TextFormat string = "text"
+	JSONFormat string = "json"
+)
+
+// A single log record, as given to a Formatter.
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Tags    []string
+	Message string
+	Fields  []Field
+}
+
+// A key/value pair of a log Entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// A Formatter renders a log Entry, including the trailing newline.
+type Formatter interface {
+	Format(Entry) []byte
+}
+
+// The plain text format, in the form of `[Tag] [Tag] message key=value`.
+// Values containing spaces or quotes are quoted.
+type TextFormatter struct {
+	// Color the tags, and the message by its level, with ANSI escape
+	// codes. See IsTerminal.
+	Color bool
+}
+
+// The ANSI escape codes used by the TextFormatter and others writing
+// to a terminal.
+const (
+	ColorReset  string = "\x1b[0m"
+	ColorFaint  string = "\x1b[2m"
+	ColorRed    string = "\x1b[31m"
+	ColorGreen  string = "\x1b[32m"
+	ColorYellow string = "\x1b[33m"
+	ColorCyan   string = "\x1b[36m"
+)
+
+// Return the color of messages of the given level, if any.
+func levelColor(lv LogLevel) string {
+	switch lv {
+	case VERBOSE, DEBUG:
+		return ColorFaint
+	case WARN:
+		return ColorYellow
+	case ERROR:
+		return ColorRed
+	}
+	return ""
+}
+
+func (f TextFormatter) Format(e Entry) []byte {
+	var b bytes.Buffer
+	for _, t := range e.Tags {
+		if f.Color {
+			fmt.Fprintf(&b, "%s[%s]%s ", ColorCyan, t, ColorReset)
+		} else {
+			fmt.Fprintf(&b, "[%s] ", t)
+		}
+	}
+	if c := levelColor(e.Level); f.Color && c != "" {
+		fmt.Fprintf(&b, "%s%s%s", c, e.Message, ColorReset)
+	} else {
+		b.WriteString(e.Message)
+	}
+	for _, fd := range e.Fields {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		if f.Color {
+			fmt.Fprintf(&b, "%s%s=%s%s", ColorFaint, fd.Key, ColorReset,
+				textValue(fd.Value))
+		} else {
+			fmt.Fprintf(&b, "%s=%s", fd.Key, textValue(fd.Value))
+		}
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// Return true if the writer is a terminal, such as an interactive
+// os.Stderr, rather than a file or pipe.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// The JSON lines format, writing every Entry as a single JSON object
+// on its own line. For example:
+//
+//	{"time":"2015-01-02T15:04:05Z","level":"info","tags":["Task"],"msg":"a starting"}
+type JSONFormatter struct{}
+
+type jsonEntry struct {
+	Time    string                 `json:"time"`
+	Level   string                 `json:"level"`
+	Tags    []string               `json:"tags"`
+	Message string                 `json:"msg"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+func (JSONFormatter) Format(e Entry) []byte {
+	je := jsonEntry{
+		Time:    e.Time.Format(time.RFC3339Nano),
+		Level:   e.Level.String(),
+		Tags:    e.Tags,
+		Message: e.Message,
+	}
+	if je.Tags == nil {
+		je.Tags = []string{}
+	}
+	if len(e.Fields) > 0 {
+		je.Fields = make(map[string]interface{}, len(e.Fields))
+		for _, f := range e.Fields {
+			je.Fields[f.Key] = jsonValue(f.Value)
+		}
+	}
+
+	b, err := json.Marshal(je)
+	if err != nil {
+		// A field could not be encoded, so fall back to their strings
+		for k, v := range je.Fields {
+			je.Fields[k] = fmt.Sprint(v)
+		}
+		b, _ = json.Marshal(je)
+	}
+	return append(b, '\n')
+}
+
+// Errors and Stringers are encoded as their strings, rather than as
+// their (often empty) structs.
+func jsonValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case error:
+		return tv.Error()
+	case fmt.Stringer:
+		return tv.String()
+	}
+	return v
+}
+
+// Return the Formatter of the given name, either "text" or "json".
+func FormatterFromString(s string) (Formatter, error) {
+	switch strings.ToLower(s) {
+	case TextFormat, "":
+		return TextFormatter{}, nil
+	case JSONFormat:
+		return JSONFormatter{}, nil
+	}
+	return nil, errors.New(fmt.Sprintf(
+		"Unknown log format '%s', expected text or json", s))
+}
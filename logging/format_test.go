@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTextFormatter(t *testing.T) {
+	Convey("Should format every tag and the message", t, func() {
+		b := TextFormatter{}.Format(Entry{
+			Level:   INFO,
+			Tags:    []string{"foo", "bar"},
+			Message: "a b",
+		})
+		So(string(b), ShouldEqual, "[foo] [bar] a b\n")
+	})
+
+	Convey("Should format the fields, quoting when needed", t, func() {
+		b := TextFormatter{}.Format(Entry{
+			Tags:    []string{"foo"},
+			Message: "a",
+			Fields: []Field{
+				{"file", "a.md"},
+				{"n", 2},
+				{"msg", `b "c"`},
+				{"empty", ""},
+			},
+		})
+		So(string(b), ShouldEqual,
+			`[foo] a file=a.md n=2 msg="b \"c\"" empty=""`+"\n")
+
+		b = TextFormatter{}.Format(Entry{Fields: []Field{{"a", 1}}})
+		So(string(b), ShouldEqual, "a=1\n")
+	})
+}
+
+func TestTextFormatterColor(t *testing.T) {
+	Convey("Should color the tags, fields and message by level", t, func() {
+		f := TextFormatter{Color: true}
+		b := f.Format(Entry{
+			Level:   WARN,
+			Tags:    []string{"foo"},
+			Message: "a",
+			Fields:  []Field{{"n", 1}},
+		})
+		So(string(b), ShouldEqual, ColorCyan+"[foo]"+ColorReset+" "+
+			ColorYellow+"a"+ColorReset+" "+ColorFaint+"n="+ColorReset+"1\n")
+
+		b = f.Format(Entry{Level: INFO, Message: "a"})
+		So(string(b), ShouldEqual, "a\n")
+	})
+}
+
+func TestIsTerminal(t *testing.T) {
+	Convey("Should be false for non files", t, func() {
+		So(IsTerminal(&bytes.Buffer{}), ShouldBeFalse)
+	})
+
+	Convey("Should be false for regular files", t, func() {
+		f, err := ioutil.TempFile("", "muta-logging")
+		So(err, ShouldBeNil)
+		defer os.Remove(f.Name())
+		defer f.Close()
+		So(IsTerminal(f), ShouldBeFalse)
+	})
+}
+
+func TestJSONFormatter(t *testing.T) {
+	Convey("Should format the Entry as a JSON line", t, func() {
+		now := time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC)
+		b := JSONFormatter{}.Format(Entry{
+			Time:    now,
+			Level:   WARN,
+			Tags:    []string{"foo", "bar"},
+			Message: "a b",
+			Fields: []Field{
+				{"file", "a.md"},
+				{"n", 2},
+				{"err", errors.New("bad")},
+			},
+		})
+		So(string(b), ShouldEqual, `{"time":"2015-01-02T15:04:05Z",`+
+			`"level":"warn","tags":["foo","bar"],"msg":"a b",`+
+			`"fields":{"err":"bad","file":"a.md","n":2}}`+"\n")
+	})
+
+	Convey("Should always include the tags", t, func() {
+		var e map[string]interface{}
+		b := JSONFormatter{}.Format(Entry{Message: "a"})
+		So(json.Unmarshal(b, &e), ShouldBeNil)
+		So(e["tags"], ShouldResemble, []interface{}{})
+		So(e["fields"], ShouldBeNil)
+	})
+
+	Convey("Should fall back to strings for invalid fields", t, func() {
+		var e map[string]interface{}
+		b := JSONFormatter{}.Format(Entry{
+			Fields: []Field{{"f", func() {}}},
+		})
+		So(json.Unmarshal(b, &e), ShouldBeNil)
+		So(e["fields"].(map[string]interface{})["f"], ShouldNotBeEmpty)
+	})
+}
+
+func TestFormatterFromString(t *testing.T) {
+	Convey("Should return the named Formatter", t, func() {
+		f, err := FormatterFromString("text")
+		So(err, ShouldBeNil)
+		So(f, ShouldHaveSameTypeAs, TextFormatter{})
+
+		f, err = FormatterFromString("JSON")
+		So(err, ShouldBeNil)
+		So(f, ShouldHaveSameTypeAs, JSONFormatter{})
+
+		_, err = FormatterFromString("xml")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestLoggerSetFormatter(t *testing.T) {
+	Convey("Should write messages with the Formatter", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		l.SetFormatter(JSONFormatter{})
+		l.Infof([]string{"foo"}, "a %d", 1)
+
+		var e map[string]interface{}
+		So(json.Unmarshal(b.Bytes(), &e), ShouldBeNil)
+		So(e["level"], ShouldEqual, "info")
+		So(e["msg"], ShouldEqual, "a 1")
+		So(e["tags"], ShouldResemble, []interface{}{"foo"})
+	})
+}
@@ -1,11 +1,14 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 var defaultLogger *Logger
@@ -25,6 +28,22 @@ const (
 )
 const DEFAULT LogLevel = INFO
 
+func (lv LogLevel) String() string {
+	switch lv {
+	case VERBOSE:
+		return "verbose"
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	}
+	return fmt.Sprintf("LogLevel(%d)", lv)
+}
+
 func LevelFromString(s string) LogLevel {
 	s = strings.ToUpper(s)
 	switch s {
@@ -43,11 +62,66 @@ func LevelFromString(s string) LogLevel {
 	}
 }
 
+// Parse a log level, returning an error if it is not known.
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "VERBOSE", "DEBUG", "INFO", "WARN", "ERROR":
+		return LevelFromString(strings.TrimSpace(s)), nil
+	}
+	return DEFAULT, errors.New(fmt.Sprintf("Unknown log level '%s'", s))
+}
+
+// The log level of messages with a tag matching Tag, which may contain
+// `*` wildcards like the tags given to SetTags.
+type TagLevel struct {
+	Tag   string
+	Level LogLevel
+}
+
+// Parse a comma separated list of log levels, in the form of
+// `level,tag=level,...`. For example:
+//
+//	info,muta.Dest=debug,markdown*=verbose
+//
+// The level without a tag is the level of all other messages, and is
+// DEFAULT if not given.
+func ParseLevels(s string) (LogLevel, []TagLevel, error) {
+	lv := DEFAULT
+	var tls []TagLevel
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.LastIndex(spec, "=")
+		if i < 0 {
+			l, err := ParseLevel(spec)
+			if err != nil {
+				return lv, nil, err
+			}
+			lv = l
+			continue
+		}
+		t := strings.TrimSpace(spec[:i])
+		if t == "" {
+			return lv, nil, errors.New(fmt.Sprintf(
+				"Missing tag in log level '%s'", spec))
+		}
+		l, err := ParseLevel(spec[i+1:])
+		if err != nil {
+			return lv, nil, err
+		}
+		tls = append(tls, TagLevel{t, l})
+	}
+	return lv, tls, nil
+}
+
 func NewLogger(w io.Writer) *Logger {
 	return &Logger{
-		writer:   w,
-		logLevel: DEFAULT,
-		tags:     nil,
+		writer:    w,
+		logLevel:  DEFAULT,
+		tags:      nil,
+		formatter: TextFormatter{},
 	}
 }
 
@@ -55,9 +129,63 @@ func NewLogger(w io.Writer) *Logger {
 // of Muta. Hopefully to be replaced in the future with a more mature
 // logger of similar style.
 type Logger struct {
-	writer   io.Writer
-	logLevel LogLevel
-	tags     []*regexp.Regexp
+	writer    io.Writer
+	logLevel  LogLevel
+	tags      []*regexp.Regexp
+	tagLevels []tagLevel
+	formatter Formatter
+
+	// Guards the writer and sinks, as tasks may log concurrently
+	mu    sync.Mutex
+	sinks []*Logger
+
+	// The Logger this was created from by With(), which is configured
+	// and written to in its place.
+	parent *Logger
+	fields []Field
+}
+
+// Return a Logger which adds the given key/value field to every
+// message it logs. For example:
+//
+//	logger.With("file", path).Debug([]string{"muta.Src"}, "Opening")
+//
+// The returned Logger shares the writer and configuration of this
+// Logger, so setting its level or tags sets them for both.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fs := make([]Field, len(l.fields), len(l.fields)+1)
+	copy(fs, l.fields)
+	return &Logger{
+		parent: l.root(),
+		fields: append(fs, Field{key, value}),
+	}
+}
+
+type tagLevel struct {
+	tag   *regexp.Regexp
+	level LogLevel
+}
+
+// Return the Logger which holds the writer and configuration.
+func (l *Logger) root() *Logger {
+	for l.parent != nil {
+		l = l.parent
+	}
+	return l
+}
+
+// Return the log level for a message with the given tags. The last
+// TagLevel matching any of the tags wins, otherwise the level of the
+// Logger is used.
+func (l *Logger) level(sts []string) LogLevel {
+	for i := len(l.tagLevels) - 1; i >= 0; i-- {
+		for _, st := range sts {
+			if l.tagLevels[i].tag.MatchString(st) {
+				return l.tagLevels[i].level
+			}
+		}
+	}
+	return l.logLevel
 }
 
 func (l *Logger) matchTags(sts []string) bool {
@@ -75,41 +203,113 @@ func (l *Logger) matchTags(sts []string) bool {
 }
 
 func (l *Logger) log(lv LogLevel, t []string, args ...interface{}) {
-	if l.logLevel > lv {
-		return
+	l.root().output(lv, t, l.fields, func() string {
+		m := fmt.Sprintln(args...)
+		return m[:len(m)-1]
+	})
+}
+
+func (l *Logger) logf(lv LogLevel, t []string, s string, args ...interface{}) {
+	l.root().output(lv, t, l.fields, func() string {
+		return strings.TrimSuffix(fmt.Sprintf(s, args...), "\n")
+	})
+}
+
+// Write the message to this Logger and each of its sinks which log
+// messages of the given level and tags. The message is only built if
+// it is written.
+func (l *Logger) output(lv LogLevel, t []string, fs []Field,
+	msg func() string) {
+
+	var e *Entry
+	l.each(func(s *Logger) {
+		if s.level(t) > lv || s.matchTags(t) == false {
+			return
+		}
+		if e == nil {
+			e = &Entry{
+				Time:    time.Now(),
+				Level:   lv,
+				Tags:    t,
+				Message: msg(),
+				Fields:  fs,
+			}
+		}
+		s.write(*e)
+	})
+}
+
+// Call fn with this Logger, and then every sink, recursively.
+func (l *Logger) each(fn func(*Logger)) {
+	fn(l)
+	l.mu.Lock()
+	sinks := l.sinks
+	l.mu.Unlock()
+	for _, s := range sinks {
+		s.each(fn)
 	}
-	if l.matchTags(t) == false {
-		return
+}
+
+// Format the Entry and write it to the writer of the Logger, if any.
+func (l *Logger) write(e Entry) {
+	f := l.formatter
+	if f == nil {
+		f = TextFormatter{}
 	}
-	if len(t) > 0 {
-		args[0] = fmt.Sprintf("[%s] %s", t[0], args[0])
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.writer != nil {
+		l.writer.Write(f.Format(e))
 	}
-	fmt.Fprintln(l.writer, args...)
 }
 
-func (l *Logger) logf(lv LogLevel, t []string, s string, args ...interface{}) {
-	if l.logLevel > lv {
-		return
-	}
-	if l.matchTags(t) == false {
+// Add a Logger which is also given every message of this Logger, and
+// writes those matching its own level and tags with its own Formatter.
+// This allows, for example, a full log to be written to a file while
+// only some of it is shown on the console:
+//
+//	f, _ := os.Create("build.log")
+//	sink := logging.NewLogger(f)
+//	sink.SetLevel(logging.VERBOSE)
+//	sink.SetFormatter(logging.JSONFormatter{})
+//	logger.AddSink(sink)
+//
+// A Logger with a nil writer only writes to its sinks.
+func (l *Logger) AddSink(s *Logger) {
+	l = l.root()
+	if s.root() == l {
 		return
 	}
-	if len(t) > 0 {
-		s = fmt.Sprintf("[%s] %s\n", t[0], s)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks[:len(l.sinks):len(l.sinks)], s.root())
+}
+
+// Remove a Logger added with AddSink.
+func (l *Logger) RemoveSink(s *Logger) {
+	l = l.root()
+	s = s.root()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var sinks []*Logger
+	for _, ls := range l.sinks {
+		if ls != s {
+			sinks = append(sinks, ls)
+		}
 	}
-	fmt.Fprintf(l.writer, s, args...)
+	l.sinks = sinks
 }
 
 // Set the tags that this logger will log. All other tags are ignored
 func (l *Logger) SetTags(tags ...string) error {
+	l = l.root()
 	if len(tags) == 0 {
 		l.tags = nil
 	} else {
 		var rs []*regexp.Regexp
 		for _, t := range tags {
-			t = strings.Replace(t, "*", ".*", -1)
-			t = fmt.Sprintf("^%s$", t)
-			r, err := regexp.Compile(t)
+			r, err := tagRegexp(t)
 			if err != nil {
 				return err
 			}
@@ -120,9 +320,72 @@ func (l *Logger) SetTags(tags ...string) error {
 	return nil
 }
 
+// Compile a tag, which may contain `*` wildcards, to a Regexp matching
+// the whole tag.
+func tagRegexp(t string) (*regexp.Regexp, error) {
+	t = strings.Replace(t, "*", ".*", -1)
+	t = fmt.Sprintf("^%s$", t)
+	return regexp.Compile(t)
+}
+
 // Set the log level that this logger wil log
 func (l *Logger) SetLevel(lv LogLevel) {
+	l.root().logLevel = lv
+}
+
+// Set the log level of messages with a tag matching the given tag,
+// which may contain `*` wildcards. Tag levels set later take precedence
+// over those set earlier.
+func (l *Logger) SetTagLevel(tag string, lv LogLevel) error {
+	r, err := tagRegexp(tag)
+	if err != nil {
+		return err
+	}
+	l = l.root()
+	l.tagLevels = append(l.tagLevels, tagLevel{r, lv})
+	return nil
+}
+
+// Set the log level and tag levels from a list of levels, such as
+// `info,muta.Dest=debug`, replacing any tag levels already set. See
+// ParseLevels for the format.
+func (l *Logger) SetLevels(s string) error {
+	lv, tls, err := ParseLevels(s)
+	if err != nil {
+		return err
+	}
+	l = l.root()
+	old := l.tagLevels
+	l.tagLevels = nil
+	for _, tl := range tls {
+		if err := l.SetTagLevel(tl.Tag, tl.Level); err != nil {
+			l.tagLevels = old
+			return err
+		}
+	}
 	l.logLevel = lv
+	return nil
+}
+
+// Set the writer that this logger writes to
+func (l *Logger) SetWriter(w io.Writer) {
+	l = l.root()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writer = w
+}
+
+// Return the writer that this logger writes to
+func (l *Logger) Writer() io.Writer {
+	l = l.root()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.writer
+}
+
+// Set the Formatter that this logger writes each message with
+func (l *Logger) SetFormatter(f Formatter) {
+	l.root().formatter = f
 }
 
 func (l *Logger) Verbose(t []string, args ...interface{}) {
@@ -162,7 +425,7 @@ func (l *Logger) Error(t []string, args ...interface{}) {
 }
 
 func (l *Logger) Errorf(t []string, s string, args ...interface{}) {
-	l.log(ERROR, t, args...)
+	l.logf(ERROR, t, s, args...)
 }
 
 // Default loggers
@@ -174,9 +437,27 @@ func DefaultLogger() *Logger {
 func SetLevel(lv LogLevel) {
 	defaultLogger.SetLevel(lv)
 }
+func SetLevels(s string) error {
+	return defaultLogger.SetLevels(s)
+}
+func SetTagLevel(tag string, lv LogLevel) error {
+	return defaultLogger.SetTagLevel(tag, lv)
+}
 func SetTags(t ...string) {
 	defaultLogger.SetTags(t...)
 }
+func SetFormatter(f Formatter) {
+	defaultLogger.SetFormatter(f)
+}
+func AddSink(s *Logger) {
+	defaultLogger.AddSink(s)
+}
+func RemoveSink(s *Logger) {
+	defaultLogger.RemoveSink(s)
+}
+func With(key string, value interface{}) *Logger {
+	return defaultLogger.With(key, value)
+}
 func Verbose(t []string, args ...interface{}) {
 	defaultLogger.Verbose(t, args...)
 }
@@ -3,6 +3,7 @@ package logging
 import (
 	"bytes"
 	"io/ioutil"
+	"strings"
 	"testing"
 )
 import . "github.com/smartystreets/goconvey/convey"
@@ -32,22 +33,22 @@ func TestLoggerSetTags(t *testing.T) {
 func TestLoggerlog(t *testing.T) {
 	Convey("Should log messages to the given writer", t, func() {
 		var b bytes.Buffer
-		l := &Logger{&b, INFO, nil}
+		l := NewLogger(&b)
 		l.log(INFO, nil, "foo")
 		l.log(INFO, nil, "bar")
 		So(b.String(), ShouldEqual, "foo\nbar\n")
 	})
 
-	Convey("Should prepend the first tag to the log", t, func() {
+	Convey("Should prepend every tag to the log", t, func() {
 		var b bytes.Buffer
-		l := &Logger{&b, INFO, nil}
+		l := NewLogger(&b)
 		l.log(INFO, []string{"foo", "bar"}, "a")
-		So(b.String(), ShouldEqual, "[foo] a\n")
+		So(b.String(), ShouldEqual, "[foo] [bar] a\n")
 	})
 
 	Convey("Should filter messages by tags", t, func() {
 		var b bytes.Buffer
-		l := &Logger{&b, INFO, nil}
+		l := NewLogger(&b)
 		l.SetTags("b*")
 		l.log(INFO, nil, "a")
 		l.log(INFO, []string{"foo"}, "b")
@@ -67,3 +68,158 @@ func TestLoggerlog(t *testing.T) {
 		So(b.String(), ShouldEqual, "d\ne\n")
 	})
 }
+
+func TestLoggerlogf(t *testing.T) {
+	Convey("Should format messages on their own line", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		l.logf(INFO, nil, "a %s", "b")
+		l.logf(INFO, []string{"foo"}, "c %d\n", 1)
+		l.Errorf(nil, "e %d", 2)
+		So(b.String(), ShouldEqual, "a b\n[foo] c 1\ne 2\n")
+	})
+}
+
+func TestLoggerWith(t *testing.T) {
+	Convey("Should add fields to the messages", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		fl := l.With("file", "a.md").With("n", 1)
+		fl.Info([]string{"foo"}, "a")
+		l.Info([]string{"foo"}, "b")
+		So(b.String(), ShouldEqual, "[foo] a file=a.md n=1\n[foo] b\n")
+	})
+
+	Convey("Should not share fields between Loggers", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b).With("a", 1)
+		l.With("b", 2)
+		l.With("c", 3).Info(nil, "x")
+		So(b.String(), ShouldEqual, "x a=1 c=3\n")
+	})
+
+	Convey("Should share the configuration of the Logger", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		fl := l.With("a", 1)
+		l.SetLevel(WARN)
+		fl.Info(nil, "x")
+		fl.Warnf(nil, "y %d", 2)
+		fl.SetTags("foo")
+		l.Warn(nil, "z")
+		So(b.String(), ShouldEqual, "y 2 a=1\n")
+	})
+}
+
+func TestParseLevels(t *testing.T) {
+	Convey("Should parse the level and tag levels", t, func() {
+		lv, tls, err := ParseLevels("warn, muta.Dest=debug,markdown*=VERBOSE")
+		So(err, ShouldBeNil)
+		So(lv, ShouldEqual, WARN)
+		So(tls, ShouldResemble, []TagLevel{
+			{"muta.Dest", DEBUG},
+			{"markdown*", VERBOSE},
+		})
+	})
+
+	Convey("Should default the level", t, func() {
+		lv, tls, err := ParseLevels("muta.Dest=debug")
+		So(err, ShouldBeNil)
+		So(lv, ShouldEqual, DEFAULT)
+		So(len(tls), ShouldEqual, 1)
+	})
+
+	Convey("Should return an error for unknown levels", t, func() {
+		_, _, err := ParseLevels("foo")
+		So(err, ShouldNotBeNil)
+		_, _, err = ParseLevels("info,muta.Dest=foo")
+		So(err, ShouldNotBeNil)
+		_, _, err = ParseLevels("info,=debug")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestLoggerSetLevels(t *testing.T) {
+	Convey("Should filter messages by the level of their tags", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		So(l.SetLevels("warn,muta.Dest=debug,markdown*=verbose"), ShouldBeNil)
+		l.Info([]string{"muta.Src"}, "a")
+		l.Debug([]string{"muta.Dest"}, "b")
+		l.Verbose([]string{"muta.Dest"}, "c")
+		l.Verbose([]string{"markdown.toc"}, "d")
+		l.Warn(nil, "e")
+		l.Info([]string{"Task", "muta.Dest"}, "f")
+		So(b.String(), ShouldEqual,
+			"[muta.Dest] b\n[markdown.toc] d\ne\n[Task] [muta.Dest] f\n")
+	})
+
+	Convey("Should prefer the last matching tag level", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(&b)
+		l.SetTagLevel("markdown*", VERBOSE)
+		l.SetTagLevel("markdown.toc", ERROR)
+		l.Warn([]string{"markdown.toc"}, "a")
+		l.Verbose([]string{"markdown"}, "b")
+		So(b.String(), ShouldEqual, "[markdown] b\n")
+	})
+
+	Convey("Should replace the tag levels", t, func() {
+		l := NewLogger(ioutil.Discard)
+		So(l.SetLevels("info,a=debug"), ShouldBeNil)
+		So(l.SetLevels("error,b=debug"), ShouldBeNil)
+		So(l.logLevel, ShouldEqual, ERROR)
+		So(len(l.tagLevels), ShouldEqual, 1)
+
+		So(l.SetLevels("foo"), ShouldNotBeNil)
+		So(l.logLevel, ShouldEqual, ERROR)
+		So(len(l.tagLevels), ShouldEqual, 1)
+	})
+}
+
+func TestLoggerAddSink(t *testing.T) {
+	Convey("Should write to every sink by their own filters", t, func() {
+		var b, sb1, sb2 bytes.Buffer
+		l := NewLogger(&b)
+		l.SetLevel(WARN)
+		l.SetTags("foo")
+
+		s1 := NewLogger(&sb1)
+		s1.SetLevel(VERBOSE)
+		s1.SetFormatter(JSONFormatter{})
+		l.AddSink(s1)
+
+		s2 := NewLogger(&sb2)
+		s2.SetTags("bar")
+		l.AddSink(s2)
+
+		l.With("n", 1).Verbose([]string{"foo"}, "a")
+		l.Info([]string{"bar"}, "b")
+		l.Warnf([]string{"foo"}, "c %d", 2)
+
+		So(b.String(), ShouldEqual, "[foo] c 2\n")
+		So(sb2.String(), ShouldEqual, "[bar] b\n")
+		lines := strings.Split(strings.TrimSpace(sb1.String()), "\n")
+		So(len(lines), ShouldEqual, 3)
+		So(lines[0], ShouldContainSubstring, `"msg":"a","fields":{"n":1}`)
+	})
+
+	Convey("Should only write to sinks without a writer", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(nil)
+		l.AddSink(NewLogger(&b))
+		l.Info(nil, "a")
+		So(b.String(), ShouldEqual, "a\n")
+	})
+
+	Convey("Should remove sinks", t, func() {
+		var b bytes.Buffer
+		l := NewLogger(nil)
+		s := NewLogger(&b)
+		l.AddSink(s)
+		l.AddSink(l)
+		l.RemoveSink(s)
+		l.Info(nil, "a")
+		So(b.String(), ShouldEqual, "")
+	})
+}
@@ -15,18 +15,10 @@ import (
 // A streamer that creates files and contents, based on the Files
 // and Contents slices.
 //
-// TODO: Find a way to move this into the `muta/mtesting` package. The
-// problem is that if this is in `muta/mtesting`, then the signature of
-// MockStreamer.Next becomes `Next() (*muta.FileInfo ...)`. SrcStreamer
-// and DestStreamer however, require that the signature of any locally
-// embedded library is `Next() (*FileInfo ...)` instead.
-//
-// When `muta` is imported into another library (such as external
-// Streamers), this appears to not be an issue, because references to
-// SrcStreamer become `muta.SrcStreamer`, and signatures become
-// `muta.FileInfo`, and so on.
-//
-// I could be way off base though - i'm not sure what to do here.
+// MockStreamer is also available as `mtesting.MockStreamer`, alongside
+// the other helpers for testing Streamers. It is defined here, rather
+// than in `muta/mtesting`, so that the tests of this package can use it
+// without an import cycle.
 type MockStreamer struct {
 	// A slice of the file names to generate. If no Content is provided,
 	// for an individual file (eg, if there are 5 files, but 4 contents)
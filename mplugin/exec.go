@@ -0,0 +1,292 @@
+package mplugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/leeola/muta"
+)
+
+func init() {
+	muta.Register("plugin", "Run an external plugin program",
+		ExecPluginOpts{}, func(o ExecPluginOpts) (muta.Streamer, error) {
+			args := strings.Fields(o.Command)
+			if len(args) == 0 {
+				return nil, errors.New("A plugin command is required")
+			}
+			s := NewExecPlugin(args[0], args[1:]...)
+			s.Dir = o.Dir
+			return s, nil
+		})
+}
+
+// The options of the "plugin" Streamer, for pipeline files.
+type ExecPluginOpts struct {
+	Command string `help:"The plugin command, and its space separated arguments"`
+	Dir     string `help:"The directory to run the plugin in"`
+}
+
+// An ExecPluginStreamer runs an external plugin program, and streams
+// files through it with the plugin protocol. See the package docs for
+// details of the protocol.
+//
+// The plugin is started on the first file, and is stopped once the
+// Stream is done with it, so a single plugin process serves every file
+// of a Stream. It is also stopped if any error occurs, or on Close().
+type ExecPluginStreamer struct {
+	// The command and arguments of the plugin
+	Command string
+	Args    []string
+
+	// The working directory and environment of the plugin, defaulting
+	// to that of muta.
+	Dir string
+	Env []string
+
+	// Where the stderr of the plugin is written, defaulting to
+	// os.Stderr.
+	Stderr io.Writer
+
+	// Starts the plugin, returning its stdin, its stdout, and a func to
+	// wait for it to exit after stdin is closed. If nil, the Command is
+	// executed.
+	start func() (io.WriteCloser, io.Reader, func(kill bool) error, error)
+
+	// The name the plugin gave in its hello, and the connection to it
+	// while it is running.
+	name  string
+	conn  *conn
+	stdin io.WriteCloser
+	wait  func(kill bool) error
+}
+
+// Return a new ExecPluginStreamer, running the given command.
+func NewExecPlugin(command string, args ...string) *ExecPluginStreamer {
+	return &ExecPluginStreamer{Command: command, Args: args}
+}
+
+// Name returns the name of the plugin, as given by the plugin once it
+// has started, or the command otherwise.
+func (s *ExecPluginStreamer) Name() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.Command
+}
+
+func (s *ExecPluginStreamer) Next(fi muta.FileInfo, rc io.ReadCloser) (
+	muta.FileInfo, io.ReadCloser, error) {
+	return muta.FuncEmitter(s.Emit).Next(fi, rc)
+}
+
+func (s *ExecPluginStreamer) Emit(fi muta.FileInfo, rc io.ReadCloser,
+	emit muta.EmitFunc) error {
+
+	if err := s.open(); err != nil {
+		if rc != nil {
+			rc.Close()
+		}
+		return err
+	}
+
+	if fi != nil && rc == nil {
+		rc = nopCloser{}
+	}
+	// The frame is written while the reply is read, so that a plugin
+	// replying before it has read the whole frame does not block on a
+	// full pipe. Stopping the plugin closes its stdin, ending the write.
+	c := s.conn
+	written := make(chan error, 1)
+	go func() {
+		written <- c.writeFrame(Header{Type: NextType, File: toFile(fi)}, rc)
+	}()
+
+	emitted := 0
+	var emitErr error
+	for {
+		h, body, err := s.conn.readFrame()
+		if err != nil {
+			return s.fail(err)
+		}
+
+		switch h.Type {
+		case FileType:
+			if h.File == nil {
+				body.Close()
+				return s.fail(errors.New("file frame without a file"))
+			}
+			// After an emit error, the remaining files are read and
+			// discarded, to reach the end of the reply.
+			if emitErr != nil {
+				body.Close()
+				continue
+			}
+
+			// The first file keeps the FileInfo of the incoming file,
+			// and any data in its Ctx.
+			var efi muta.FileInfo
+			if emitted == 0 && fi != nil {
+				efi = fi
+				h.File.apply(efi)
+			} else {
+				efi = h.File.FileInfo()
+			}
+			emitted++
+			emitErr = emit(efi, body)
+
+		case DoneType:
+			if emitErr != nil {
+				s.stop(true)
+				return emitErr
+			}
+			if err := <-written; err != nil {
+				return s.fail(err)
+			}
+			// Asked to generate, and the plugin has nothing more. The
+			// Stream will not call again, so the plugin is done.
+			if fi == nil && emitted == 0 {
+				return s.stop(false)
+			}
+			return nil
+
+		case ErrorType:
+			s.stop(true)
+			if emitErr != nil {
+				return emitErr
+			}
+			return errors.New(fmt.Sprintf("Plugin %s: %s", s.Name(), h.Error))
+
+		default:
+			if body != nil {
+				body.Close()
+			}
+			return s.fail(errors.New(fmt.Sprintf(
+				"unexpected %s frame", h.Type)))
+		}
+	}
+}
+
+// Close stops the plugin, if it is running, waiting for it to exit.
+func (s *ExecPluginStreamer) Close() error {
+	return s.stop(false)
+}
+
+func (s *ExecPluginStreamer) Describe() muta.Description {
+	return muta.Description{
+		Name: "mplugin.ExecPlugin",
+		Options: map[string]interface{}{
+			"Command": s.Command,
+			"Args":    s.Args,
+		},
+	}
+}
+
+// open starts the plugin and exchanges hellos, if it is not running.
+func (s *ExecPluginStreamer) open() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	start := s.start
+	if start == nil {
+		start = s.execStart
+	}
+	stdin, stdout, wait, err := start()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Unable to start plugin %s: %s",
+			s.Command, err))
+	}
+	s.conn, s.stdin, s.wait = newConn(stdout, stdin), stdin, wait
+
+	err = s.conn.writeFrame(Header{
+		Type:    HelloType,
+		Version: ProtocolVersion,
+	}, nil)
+	if err != nil {
+		return s.fail(err)
+	}
+	h, body, err := s.conn.readFrame()
+	if body != nil {
+		body.Close()
+	}
+	if err != nil {
+		return s.fail(err)
+	}
+	if h.Type != HelloType {
+		return s.fail(errors.New(fmt.Sprintf(
+			"expected a hello frame, got %s", h.Type)))
+	}
+	if h.Version != ProtocolVersion {
+		return s.fail(errors.New(fmt.Sprintf(
+			"unsupported protocol version %d", h.Version)))
+	}
+	s.name = h.Name
+	return nil
+}
+
+// execStart runs the Command.
+func (s *ExecPluginStreamer) execStart() (io.WriteCloser, io.Reader,
+	func(bool) error, error) {
+
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Dir = s.Dir
+	cmd.Env = s.Env
+	cmd.Stderr = s.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	wait := func(kill bool) error {
+		if kill {
+			cmd.Process.Kill()
+		}
+		return cmd.Wait()
+	}
+	return stdin, stdout, wait, nil
+}
+
+// stop closes the stdin of the plugin and waits for it to exit, killing
+// it first if kill is true. The exit error is returned, unless killed.
+func (s *ExecPluginStreamer) stop(kill bool) error {
+	if s.conn == nil {
+		return nil
+	}
+	s.stdin.Close()
+	err := s.wait(kill)
+	s.conn, s.stdin, s.wait = nil, nil, nil
+	if kill || err == nil {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Plugin %s exited: %s", s.Name(), err))
+}
+
+// fail kills the plugin after a protocol error, returning the error.
+func (s *ExecPluginStreamer) fail(err error) error {
+	s.stop(true)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New(fmt.Sprintf(
+			"Plugin %s exited unexpectedly", s.Name()))
+	}
+	return errors.New(fmt.Sprintf("Plugin %s: %s", s.Name(), err))
+}
+
+// An empty io.ReadCloser, for files without content.
+type nopCloser struct{}
+
+func (nopCloser) Read([]byte) (int, error) { return 0, io.EOF }
+func (nopCloser) Close() error             { return nil }
@@ -0,0 +1,38 @@
+package mplugin
+
+import (
+	"io"
+
+	"github.com/leeola/muta"
+)
+
+// InProcess returns an ExecPluginStreamer which serves the given
+// Streamer from a goroutine, rather than an external program. Files
+// still pass through the protocol, so it can be used to test Go plugins
+// without building them, and to test hosts against a known plugin.
+func InProcess(name string, sr muta.Streamer) *ExecPluginStreamer {
+	s := &ExecPluginStreamer{Command: name}
+	s.start = func() (io.WriteCloser, io.Reader, func(bool) error, error) {
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+
+		done := make(chan error, 1)
+		go func() {
+			err := Serve(name, sr, inR, outW)
+			// Unblock the host if the plugin stopped early
+			outW.CloseWithError(io.EOF)
+			inR.Close()
+			done <- err
+		}()
+
+		wait := func(kill bool) error {
+			if kill {
+				inR.Close()
+				outR.Close()
+			}
+			return <-done
+		}
+		return inW, outR, wait, nil
+	}
+	return s
+}
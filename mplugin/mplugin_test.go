@@ -0,0 +1,323 @@
+package mplugin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// When run as a plugin by TestExecPluginStreamer, serve upper() rather
+// than running the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("MUTA_TEST_PLUGIN") == "1" {
+		Main("upper", upper())
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// upper uppercases the content of every file, and fails on files
+// named "fail".
+func upper() muta.Streamer {
+	return muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+		muta.FileInfo, io.ReadCloser, error) {
+		if fi == nil {
+			return nil, nil, nil
+		}
+		defer rc.Close()
+		if fi.Name() == "fail" {
+			return nil, nil, errors.New("failed")
+		}
+		b, err := ioutil.ReadAll(rc)
+		fi.SetName(strings.ToUpper(fi.Name()))
+		return fi, mutil.ByteCloser(bytes.ToUpper(b)), err
+	})
+}
+
+type output struct {
+	Name    string
+	Path    string
+	Content string
+}
+
+// Stream the given files through the Streamer, returning the output.
+func run(sr muta.Streamer, files ...string) ([]output, error) {
+	var out []output
+	s := muta.Stream{
+		&muta.MockStreamer{Files: files},
+		sr,
+		muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+			muta.FileInfo, io.ReadCloser, error) {
+			if fi == nil {
+				return nil, nil, nil
+			}
+			defer rc.Close()
+			b, err := ioutil.ReadAll(rc)
+			out = append(out, output{fi.Name(), fi.Path(), string(b)})
+			return nil, nil, err
+		}),
+	}
+	return out, s.Stream()
+}
+
+func TestInProcess(t *testing.T) {
+	Convey("Should stream files through the plugin", t, func() {
+		p := InProcess("upper", upper())
+		out, err := run(p, "foo", "bar")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, []output{
+			{"FOO", ".", "FOO CONTENT"},
+			{"BAR", ".", "BAR CONTENT"},
+		})
+		So(p.Name(), ShouldEqual, "upper")
+
+		// The plugin is stopped at the end of the Stream
+		So(p.conn, ShouldBeNil)
+	})
+
+	Convey("Should keep the FileInfo of the incoming file", t, func() {
+		p := InProcess("upper", upper())
+		fi := muta.NewFileInfo("a/foo")
+		fi.SetCtx("key", "value")
+		ofi, rc, err := p.Next(fi, mutil.StringCloser("foo"))
+		So(err, ShouldBeNil)
+		So(ofi, ShouldEqual, fi)
+		So(ofi.Name(), ShouldEqual, "FOO")
+		So(ofi.Path(), ShouldEqual, "a")
+		So(ofi.OriginalName(), ShouldEqual, "foo")
+		So(ofi.Ctx("key"), ShouldEqual, "value")
+		b, _ := ioutil.ReadAll(rc)
+		So(string(b), ShouldEqual, "FOO")
+		So(p.Close(), ShouldBeNil)
+	})
+
+	Convey("Should emit every file output by an Emitter", t, func() {
+		p := InProcess("double", muta.FuncEmitter(func(fi muta.FileInfo,
+			rc io.ReadCloser, emit muta.EmitFunc) error {
+			if fi == nil {
+				return nil
+			}
+			defer rc.Close()
+			b, _ := ioutil.ReadAll(rc)
+			if err := emit(fi, mutil.ByteCloser(b)); err != nil {
+				return err
+			}
+			gz := muta.NewFileInfo(fi.Name() + ".gz")
+			return emit(gz, mutil.ByteCloser(b))
+		}))
+		out, err := run(p, "foo")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, []output{
+			{"foo", ".", "foo content"},
+			{"foo.gz", ".", "foo content"},
+		})
+	})
+
+	Convey("Should stream files generated by the plugin", t, func() {
+		n := 0
+		p := InProcess("gen", muta.FuncStreamer(func(fi muta.FileInfo,
+			rc io.ReadCloser) (muta.FileInfo, io.ReadCloser, error) {
+			if fi != nil || n == 2 {
+				return fi, rc, nil
+			}
+			n++
+			return muta.NewFileInfo("gen"), mutil.StringCloser("gen"), nil
+		}))
+		out, err := run(p)
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, []output{
+			{"gen", ".", "gen"},
+			{"gen", ".", "gen"},
+		})
+	})
+
+	Convey("Should return errors of the plugin", t, func() {
+		p := InProcess("upper", upper())
+		_, err := run(p, "foo", "fail")
+		So(err, ShouldResemble, errors.New("Plugin upper: failed"))
+		So(p.conn, ShouldBeNil)
+	})
+}
+
+func TestExecPluginStreamer(t *testing.T) {
+	Convey("Should run an external plugin", t, func() {
+		p := NewExecPlugin(os.Args[0])
+		p.Env = append(os.Environ(), "MUTA_TEST_PLUGIN=1")
+		out, err := run(p, "foo", "bar")
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, []output{
+			{"FOO", ".", "FOO CONTENT"},
+			{"BAR", ".", "BAR CONTENT"},
+		})
+		So(p.Name(), ShouldEqual, "upper")
+		So(p.conn, ShouldBeNil)
+	})
+
+	Convey("Should be stopped by Close", t, func() {
+		p := NewExecPlugin(os.Args[0])
+		p.Env = append(os.Environ(), "MUTA_TEST_PLUGIN=1")
+		_, _, err := p.Next(muta.NewFileInfo("foo"), mutil.StringCloser("foo"))
+		So(err, ShouldBeNil)
+		So(p.conn, ShouldNotBeNil)
+		So(p.Close(), ShouldBeNil)
+		So(p.conn, ShouldBeNil)
+	})
+
+	Convey("Should return an error if the plugin does not start", t, func() {
+		p := NewExecPlugin("muta-plugin-that-does-not-exist")
+		_, err := run(p, "foo")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should return an error if the plugin exits", t, func() {
+		// The test binary, run without the env, runs no tests and
+		// exits without speaking the protocol.
+		p := NewExecPlugin(os.Args[0], "-test.run=^$")
+		p.Stderr = ioutil.Discard
+		_, err := run(p, "foo")
+		So(err, ShouldNotBeNil)
+		So(p.conn, ShouldBeNil)
+	})
+
+	Convey("Should be registered as a Streamer", t, func() {
+		rs, err := muta.LookupStreamer("plugin")
+		So(err, ShouldBeNil)
+		sr, err := rs.New(map[string]interface{}{"command": "foo bar"})
+		So(err, ShouldBeNil)
+		So(sr.(*ExecPluginStreamer).Command, ShouldEqual, "foo")
+		So(sr.(*ExecPluginStreamer).Args, ShouldResemble, []string{"bar"})
+
+		_, err = rs.New(nil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// eager serves a plugin on the given pipes which replies to each "next"
+// frame as soon as it has read its header, before reading the body.
+func eager(in io.Reader, out io.Writer) error {
+	c := newConn(in, out)
+	if _, _, err := c.readFrame(); err != nil {
+		return err
+	}
+	if err := c.writeFrame(Header{Type: HelloType, Name: "eager",
+		Version: ProtocolVersion}, nil); err != nil {
+		return err
+	}
+	for {
+		var hSize uint32
+		if err := binary.Read(c.r, binary.BigEndian, &hSize); err != nil {
+			return nil
+		}
+		var h Header
+		if err := json.NewDecoder(io.LimitReader(c.r,
+			int64(hSize))).Decode(&h); err != nil {
+			return err
+		}
+		var bSize uint64
+		if err := binary.Read(c.r, binary.BigEndian, &bSize); err != nil {
+			return err
+		}
+		if h.File != nil {
+			err := c.writeFrame(Header{Type: FileType, File: h.File},
+				mutil.StringCloser("eager"))
+			if err != nil {
+				return err
+			}
+		}
+		if err := c.writeFrame(Header{Type: DoneType}, nil); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(ioutil.Discard, c.r, int64(bSize)); err != nil {
+			return err
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	Convey("Should read replies while writing a frame", t, func() {
+		p := &ExecPluginStreamer{Command: "eager"}
+		p.start = func() (io.WriteCloser, io.Reader, func(bool) error,
+			error) {
+			inR, inW := io.Pipe()
+			outR, outW := io.Pipe()
+			done := make(chan error, 1)
+			go func() {
+				err := eager(inR, outW)
+				outW.CloseWithError(io.EOF)
+				done <- err
+			}()
+			wait := func(bool) error {
+				inR.Close()
+				return <-done
+			}
+			return inW, outR, wait, nil
+		}
+
+		var out []output
+		var err error
+		finished := make(chan struct{})
+		go func() {
+			big := strings.Repeat("a", 1<<16)
+			out, err = run(muta.Stream{
+				&muta.MockStreamer{Files: []string{"foo"}},
+				muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+					muta.FileInfo, io.ReadCloser, error) {
+					return fi, mutil.StringCloser(big), nil
+				}),
+				p,
+			})
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+		}
+		So(err, ShouldBeNil)
+		So(out, ShouldResemble, []output{{"foo", ".", "eager"}})
+	})
+
+	Convey("Should read the frames written", t, func() {
+		var b bytes.Buffer
+		c := newConn(&b, &b)
+		fi := muta.NewFileInfo("a/foo")
+		So(c.writeFrame(Header{Type: FileType, File: toFile(fi)},
+			mutil.StringCloser("content")), ShouldBeNil)
+		So(c.writeFrame(Header{Type: DoneType}, nil), ShouldBeNil)
+
+		h, body, err := c.readFrame()
+		So(err, ShouldBeNil)
+		So(h.File, ShouldResemble, &File{"foo", "a", "foo", "a"})
+		content, _ := ioutil.ReadAll(body)
+		So(string(content), ShouldEqual, "content")
+
+		h, body, err = c.readFrame()
+		So(err, ShouldBeNil)
+		So(h.Type, ShouldEqual, DoneType)
+		So(body, ShouldBeNil)
+
+		_, _, err = c.readFrame()
+		So(err, ShouldEqual, io.EOF)
+	})
+
+	Convey("Should return an error for truncated frames", t, func() {
+		var b bytes.Buffer
+		c := newConn(&b, &b)
+		c.writeFrame(Header{Type: FileType, File: &File{Name: "foo"}},
+			mutil.StringCloser("content"))
+		b.Truncate(b.Len() - 2)
+
+		_, _, err := c.readFrame()
+		So(err, ShouldEqual, io.ErrUnexpectedEOF)
+	})
+}
@@ -0,0 +1,220 @@
+//
+// # Muta Plugin
+//
+// Run Streamers as external programs, so that plugins can be written
+// in any language. The host runs the plugin with ExecPluginStreamer,
+// and the plugin serves a single Streamer on its stdin and stdout.
+// Serve() and Main() are the plugin side, for plugins written in Go.
+//
+// ## Protocol
+//
+// Every message, in both directions, is a frame of:
+//
+//	uint32  The big endian length of the header
+//	[]byte  The JSON Header
+//	uint64  The big endian length of the body
+//	[]byte  The body, the content of the file in the header if any
+//
+// The host starts with a "hello" frame, and the plugin replies with a
+// "hello" frame of its own, naming itself. For every call of the
+// Streamer, the host then sends a "next" frame, with the incoming file
+// and its content. A "next" frame without a file asks the plugin to
+// generate new files, as with a nil FileInfo given to a Streamer.
+//
+// The plugin replies with a "file" frame for every file it outputs,
+// followed by a "done" frame, or an "error" frame if the Streamer
+// returned an error. Replying with no files drops the incoming file,
+// and once a "next" frame without a file is answered with no files the
+// host closes the plugin's stdin. The plugin should then exit.
+//
+// The host writes each frame while reading the reply, so a plugin may
+// start replying before it has read the whole frame. It must still read
+// the rest of the frame before the next one.
+//
+// Plugins must not write anything else to stdout. Logs should be
+// written to stderr, which is passed through to muta.
+//
+package mplugin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mutil"
+)
+
+// The version of the protocol, sent in the hello frames.
+const ProtocolVersion int = 1
+
+// The types of Header.
+const (
+	HelloType string = "hello"
+	NextType  string = "next"
+	FileType  string = "file"
+	DoneType  string = "done"
+	ErrorType string = "error"
+)
+
+// The largest header accepted, to protect against corrupt streams.
+const maxHeaderSize uint32 = 1 << 20
+
+// The Header of a frame.
+type Header struct {
+	Type string `json:"type"`
+
+	// The version of the protocol, and the name of the plugin, in hello
+	// frames.
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+
+	// The file of next and file frames. The body of the frame is the
+	// file's content.
+	File *File `json:"file,omitempty"`
+
+	// The message of error frames.
+	Error string `json:"error,omitempty"`
+}
+
+// File is the FileInfo of a file, as sent over the protocol.
+type File struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	OriginalName string `json:"original_name"`
+	OriginalPath string `json:"original_path"`
+}
+
+// Return the File of the given FileInfo, or nil if there is none.
+func toFile(fi muta.FileInfo) *File {
+	if fi == nil {
+		return nil
+	}
+	return &File{
+		Name:         fi.Name(),
+		Path:         fi.Path(),
+		OriginalName: fi.OriginalName(),
+		OriginalPath: fi.OriginalPath(),
+	}
+}
+
+// Return a new FileInfo for the File, or nil if there is none.
+func (f *File) FileInfo() muta.FileInfo {
+	if f == nil {
+		return nil
+	}
+	fi := muta.NewFileInfo(filepath.Join(f.OriginalPath, f.OriginalName))
+	f.apply(fi)
+	return fi
+}
+
+// Set the name and path of the FileInfo to that of the File.
+func (f *File) apply(fi muta.FileInfo) {
+	fi.SetName(f.Name)
+	fi.SetPath(f.Path)
+}
+
+// A conn reads and writes frames.
+type conn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+}
+
+// writeFrame writes the header, and the content of rc as the body, if
+// any. rc is closed.
+func (c *conn) writeFrame(h Header, rc io.ReadCloser) error {
+	var body *mutil.SpillBuffer
+	if rc != nil {
+		// The content is buffered, as its length is needed up front.
+		var err error
+		body, err = mutil.NewSpillBuffer(rc, muta.MaxMemoryBuffer)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+	}
+
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(c.w, binary.BigEndian, uint32(len(hb))); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(hb); err != nil {
+		return err
+	}
+
+	var size int64
+	if body != nil {
+		size = body.Size()
+	}
+	if err := binary.Write(c.w, binary.BigEndian, uint64(size)); err != nil {
+		return err
+	}
+	if body != nil {
+		if _, err := io.Copy(c.w, body); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+// readFrame reads the next frame. The body is nil if it is empty and
+// the frame has no file. io.EOF is returned if the stream ended
+// cleanly, before a frame.
+func (c *conn) readFrame() (Header, io.ReadCloser, error) {
+	var h Header
+	var hSize uint32
+	if err := binary.Read(c.r, binary.BigEndian, &hSize); err != nil {
+		return h, nil, err
+	}
+	if hSize > maxHeaderSize {
+		return h, nil, errors.New(fmt.Sprintf(
+			"header of %d bytes exceeds the maximum", hSize))
+	}
+
+	hb := make([]byte, hSize)
+	if _, err := io.ReadFull(c.r, hb); err != nil {
+		return h, nil, unexpectedEOF(err)
+	}
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return h, nil, err
+	}
+
+	var bSize uint64
+	if err := binary.Read(c.r, binary.BigEndian, &bSize); err != nil {
+		return h, nil, unexpectedEOF(err)
+	}
+	if bSize == 0 && h.File == nil {
+		return h, nil, nil
+	}
+
+	body, err := mutil.NewSpillBuffer(io.LimitReader(c.r, int64(bSize)),
+		muta.MaxMemoryBuffer)
+	if err != nil {
+		return h, nil, err
+	}
+	if body.Size() != int64(bSize) {
+		body.Close()
+		return h, nil, io.ErrUnexpectedEOF
+	}
+	return h, body, nil
+}
+
+// An EOF within a frame is unexpected.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
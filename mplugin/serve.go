@@ -0,0 +1,130 @@
+package mplugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/leeola/muta"
+)
+
+// Main serves the Streamer as a plugin on stdin and stdout, exiting
+// once muta is done with it. This is usually all the main() of a Go
+// plugin needs to call:
+//
+//	func main() {
+//		mplugin.Main("upper", Upper())
+//	}
+func Main(name string, sr muta.Streamer) {
+	if err := Serve(name, sr, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// Serve runs the plugin side of the protocol, reading frames from r and
+// writing frames to w, until r is closed. Every "next" frame is given
+// to the Streamer, with the Streamer's errors returned to the host as
+// "error" frames.
+//
+// If the Streamer is a muta.Emitter, Emit() is called instead of Next(),
+// so that it can output many files for a single file.
+func Serve(name string, sr muta.Streamer, r io.Reader, w io.Writer) error {
+	c := newConn(r, w)
+
+	h, body, err := c.readFrame()
+	if body != nil {
+		body.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if h.Type != HelloType {
+		return errors.New(fmt.Sprintf("expected a hello frame, got %s",
+			h.Type))
+	}
+	if h.Version != ProtocolVersion {
+		return errors.New(fmt.Sprintf("unsupported protocol version %d",
+			h.Version))
+	}
+	err = c.writeFrame(Header{
+		Type:    HelloType,
+		Version: ProtocolVersion,
+		Name:    name,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		h, body, err := c.readFrame()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Type != NextType {
+			if body != nil {
+				body.Close()
+			}
+			return errors.New(fmt.Sprintf("expected a next frame, got %s",
+				h.Type))
+		}
+
+		// Write errors are kept apart from the Streamer's errors, as
+		// the connection can no longer be used.
+		var writeErr error
+		emit := func(fi muta.FileInfo, rc io.ReadCloser) error {
+			if writeErr != nil {
+				if rc != nil {
+					rc.Close()
+				}
+				return writeErr
+			}
+			if rc == nil {
+				rc = nopCloser{}
+			}
+			writeErr = c.writeFrame(Header{Type: FileType, File: toFile(fi)},
+				rc)
+			return writeErr
+		}
+
+		err = call(sr, h.File.FileInfo(), body, emit)
+		if writeErr != nil {
+			return writeErr
+		}
+		if err != nil {
+			err = c.writeFrame(Header{Type: ErrorType, Error: err.Error()}, nil)
+		} else {
+			err = c.writeFrame(Header{Type: DoneType}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// call gives the file to the Streamer, emitting its output.
+func call(sr muta.Streamer, fi muta.FileInfo, rc io.ReadCloser,
+	emit muta.EmitFunc) error {
+
+	if e, ok := sr.(muta.Emitter); ok {
+		return e.Emit(fi, rc, emit)
+	}
+	fi, rc, err := sr.Next(fi, rc)
+	if err != nil {
+		if rc != nil {
+			rc.Close()
+		}
+		return err
+	}
+	if fi == nil {
+		if rc != nil {
+			rc.Close()
+		}
+		return nil
+	}
+	return emit(fi, rc)
+}
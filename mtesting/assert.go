@@ -0,0 +1,100 @@
+package mtesting
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The assertions below can be used with goconvey's So(), or on their
+// own, as they return an empty string on success and a message
+// otherwise. For example:
+//
+//	So(out, mtesting.ShouldHaveNames, "index.html", "about.html")
+//
+//	if msg := mtesting.ShouldHaveNames(out, "index.html"); msg != "" {
+//		t.Error(msg)
+//	}
+
+// ShouldHaveNames asserts that the Files have the expected names, in
+// order.
+func ShouldHaveNames(actual interface{}, expected ...interface{}) string {
+	fs, msg := files(actual)
+	if msg != "" {
+		return msg
+	}
+	return resemble("names", fs.Names(), expected)
+}
+
+// ShouldHavePaths asserts that the Files have the expected full paths,
+// in order.
+func ShouldHavePaths(actual interface{}, expected ...interface{}) string {
+	fs, msg := files(actual)
+	if msg != "" {
+		return msg
+	}
+	return resemble("paths", fs.Paths(), expected)
+}
+
+// ShouldHaveContents asserts that the Files have the expected contents,
+// in order.
+func ShouldHaveContents(actual interface{}, expected ...interface{}) string {
+	fs, msg := files(actual)
+	if msg != "" {
+		return msg
+	}
+	return resemble("contents", fs.Contents(), expected)
+}
+
+// ShouldHaveFile asserts that the Files contain a file with the
+// expected full path and, if given, the expected content.
+func ShouldHaveFile(actual interface{}, expected ...interface{}) string {
+	fs, msg := files(actual)
+	if msg != "" {
+		return msg
+	}
+	if len(expected) < 1 || len(expected) > 2 {
+		return "ShouldHaveFile expects a path, and optionally a content"
+	}
+	p, ok := expected[0].(string)
+	if !ok {
+		return fmt.Sprintf("Expected a string path, got %T", expected[0])
+	}
+
+	f, ok := fs.Lookup(p)
+	if !ok {
+		return fmt.Sprintf("Expected a file %s, but only got %v", p,
+			fs.Paths())
+	}
+	if len(expected) == 2 {
+		c := fmt.Sprint(expected[1])
+		if string(f.Content) != c {
+			return fmt.Sprintf("Expected %s to contain %q, but got %q", p, c,
+				f.Content)
+		}
+	}
+	return ""
+}
+
+// Return the actual value of an assertion as Files.
+func files(actual interface{}) (Files, string) {
+	switch fs := actual.(type) {
+	case Files:
+		return fs, ""
+	case []File:
+		return Files(fs), ""
+	case File:
+		return Files{fs}, ""
+	}
+	return nil, fmt.Sprintf("Expected mtesting.Files, got %T", actual)
+}
+
+func resemble(what string, actual []string, expected []interface{}) string {
+	es := make([]string, len(expected))
+	for i, e := range expected {
+		es[i] = fmt.Sprint(e)
+	}
+	if !reflect.DeepEqual(actual, es) {
+		return fmt.Sprintf("Expected the %s %q, but got %q", what, es, actual)
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package mtesting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/leeola/muta"
+)
+
+// The most files a Streamer may output while its contract is checked,
+// so that a Streamer which never stops generating files fails rather
+// than hangs.
+const contractLimit int = 10000
+
+var errTooManyFiles = errors.New(fmt.Sprintf(
+	"output more than %d files", contractLimit))
+
+// The file given to CheckContract's pass through rule, which no
+// Streamer should handle.
+var UnknownFile = File{
+	Info:    muta.NewFileInfo("mtesting/unknown.mtesting-unknown"),
+	Content: []byte("mtesting unknown content"),
+}
+
+// ContractError lists the rules of the Streamer contract broken by a
+// Streamer.
+type ContractError struct {
+	Streamer string
+	Broken   []string
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("Streamer %s broke the contract: %s", e.Streamer,
+		strings.Join(e.Broken, "; "))
+}
+
+// CheckContract checks that the Streamers returned by newStreamer
+// follow the rules every Streamer is expected to follow, in a Stream:
+//
+//   - Every reader it is given is closed, or passed on
+//   - Files it does not handle are passed on unchanged
+//   - Being called with a nil file does not fail, and eventually
+//     returns no file
+//
+// Each rule is checked with a new Streamer. The inputs are used to
+// check that readers are closed, and should be files the Streamer
+// handles. UnknownFile is used to check the pass through. If every
+// rule is followed nil is returned, otherwise a *ContractError.
+func CheckContract(newStreamer func() muta.Streamer,
+	inputs ...File) error {
+
+	e := &ContractError{Streamer: muta.Describe(newStreamer()).Name}
+	checks := []func(muta.Streamer) error{
+		func(sr muta.Streamer) error {
+			return CheckClosesReaders(sr, copyFiles(inputs)...)
+		},
+		func(sr muta.Streamer) error {
+			return CheckPassesUnknown(sr, copyFiles([]File{UnknownFile})[0])
+		},
+		CheckNilInput,
+	}
+	for _, check := range checks {
+		if err := check(newStreamer()); err != nil {
+			e.Broken = append(e.Broken, err.Error())
+		}
+	}
+	if len(e.Broken) > 0 {
+		return e
+	}
+	return nil
+}
+
+// CheckClosesReaders returns an error if any of the readers of the
+// inputs were neither closed by the Streamer nor passed on by it.
+func CheckClosesReaders(sr muta.Streamer, inputs ...File) (err error) {
+	defer recoverError(&err)
+	// The Streamer may rename the inputs
+	ps := make([]string, len(inputs))
+	for i, f := range inputs {
+		ps[i] = f.Path()
+	}
+	_, rcs, err := run(sr, contractLimit, inputs)
+	if err != nil {
+		return err
+	}
+	for i, rc := range rcs {
+		if !rc.done() {
+			return errors.New(fmt.Sprintf(
+				"the reader of %s was neither closed nor passed on", ps[i]))
+		}
+	}
+	return nil
+}
+
+// CheckPassesUnknown returns an error unless the Streamer outputs the
+// given file, which it should not handle, with the same path and
+// content.
+func CheckPassesUnknown(sr muta.Streamer, f File) (err error) {
+	defer recoverError(&err)
+	p, content := f.Path(), f.Content
+	out, _, err := run(sr, contractLimit, []File{f})
+	if err != nil {
+		return err
+	}
+	uf, ok := out.Lookup(p)
+	if !ok {
+		return errors.New(fmt.Sprintf("the unknown file %s was not passed on",
+			p))
+	}
+	if !bytes.Equal(uf.Content, content) {
+		return errors.New(fmt.Sprintf(
+			"the content of the unknown file %s was changed", p))
+	}
+	return nil
+}
+
+// CheckNilInput returns an error if the Streamer fails, panics, or
+// never stops generating files when called with a nil file and no
+// inputs.
+func CheckNilInput(sr muta.Streamer) (err error) {
+	defer recoverError(&err)
+	_, _, err = run(sr, contractLimit, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("with a nil file: %s", err))
+	}
+	return nil
+}
+
+// Return a panic of the calling function as its error.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = errors.New(fmt.Sprintf("panicked: %v", r))
+	}
+}
+
+// Return copies of the files with new FileInfo, since the Streamers
+// of earlier checks may have changed them.
+func copyFiles(fs []File) []File {
+	cs := make([]File, len(fs))
+	for i, f := range fs {
+		fi := muta.NewFileInfo(filepath.Join(f.Info.OriginalPath(),
+			f.Info.OriginalName()))
+		fi.SetName(f.Info.Name())
+		fi.SetPath(f.Info.Path())
+		cs[i] = File{Info: fi, Content: f.Content}
+	}
+	return cs
+}
@@ -0,0 +1,210 @@
+//
+// # Muta Testing
+//
+// Helpers for testing Streamers. Run() pipes in-memory files through a
+// Streamer, as a Stream would, and collects every file it outputs
+// along with its content. For example, with goconvey:
+//
+//	out, err := mtesting.Run(Markdown(),
+//		mtesting.NewFile("pages/index.md", "# Hello"))
+//	So(err, ShouldBeNil)
+//	So(out, mtesting.ShouldHavePaths, "pages/index.html")
+//	So(out, mtesting.ShouldHaveContents, "<h1>Hello</h1>\n")
+//
+// CheckContract() checks the rules every Streamer is expected to
+// follow, such as closing the readers it is given.
+//
+package mtesting
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/leeola/muta"
+)
+
+// MockStreamer generates files from a list of names and contents. See
+// muta.MockStreamer.
+type MockStreamer = muta.MockStreamer
+
+// File is an in-memory file, given to or collected from a Streamer.
+type File struct {
+	Info    muta.FileInfo
+	Content []byte
+}
+
+// NewFile returns a File at the given path, such as "pages/index.md",
+// with the given content.
+func NewFile(p, content string) File {
+	return File{Info: muta.NewFileInfo(p), Content: []byte(content)}
+}
+
+// Path returns the full path of the File, its Path joined with its
+// Name.
+func (f File) Path() string {
+	return filepath.Join(f.Info.Path(), f.Info.Name())
+}
+
+// Files are the files collected by Run(), in the order they were
+// output.
+type Files []File
+
+// Names returns the Name of every File.
+func (fs Files) Names() []string {
+	ns := make([]string, len(fs))
+	for i, f := range fs {
+		ns[i] = f.Info.Name()
+	}
+	return ns
+}
+
+// Paths returns the full path of every File.
+func (fs Files) Paths() []string {
+	ps := make([]string, len(fs))
+	for i, f := range fs {
+		ps[i] = f.Path()
+	}
+	return ps
+}
+
+// Contents returns the content of every File, as strings.
+func (fs Files) Contents() []string {
+	cs := make([]string, len(fs))
+	for i, f := range fs {
+		cs[i] = string(f.Content)
+	}
+	return cs
+}
+
+// Lookup returns the first File with the given full path.
+func (fs Files) Lookup(p string) (File, bool) {
+	p = filepath.Clean(p)
+	for _, f := range fs {
+		if f.Path() == p {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Run pipes every input file through the Streamer, and then calls it
+// with a nil file until it stops generating files, exactly as a Stream
+// does. Every file the Streamer outputs is read and closed, and
+// returned in order.
+//
+// The FileInfo of the inputs are given to the Streamer as is, so they
+// may be changed by it. If the Streamer implements io.Closer, it is
+// closed once it is done, as a Tasker would, through Stream.Close.
+func Run(sr muta.Streamer, inputs ...File) (Files, error) {
+	out, _, err := run(sr, 0, inputs)
+	return out, err
+}
+
+// run is Run, also returning the readers given to the Streamer, which
+// record whether the Streamer closed them or passed them on. If limit
+// is not zero, the Streamer is stopped with an error once it outputs
+// more files than that.
+func run(sr muta.Streamer, limit int, inputs []File) (Files, []*readCloser,
+	error) {
+
+	src := &source{files: inputs}
+	s := muta.Stream{src, sr}
+
+	var out Files
+	err := s.Emit(nil, nil, func(fi muta.FileInfo, rc io.ReadCloser) error {
+		if limit > 0 && len(out) >= limit {
+			if rc != nil {
+				src.pass(rc)
+			}
+			return errTooManyFiles
+		}
+
+		var b []byte
+		if rc != nil {
+			var err error
+			b, err = ioutil.ReadAll(rc)
+			src.pass(rc)
+			if err != nil {
+				return err
+			}
+		}
+		out = append(out, File{Info: fi, Content: b})
+		return nil
+	})
+
+	if cErr := s.Close(); err == nil {
+		err = cErr
+	}
+	return out, src.readers, err
+}
+
+// source outputs the inputs of Run(), one at a time, recording the
+// readers it creates for them.
+type source struct {
+	files   []File
+	readers []*readCloser
+
+	// Set while closing a file output by the Streamer, so that its
+	// readers, even if wrapped by others, are recorded as passed on
+	// rather than closed.
+	mu      sync.Mutex
+	passing bool
+}
+
+// pass closes a reader output by the Streamer.
+func (s *source) pass(rc io.ReadCloser) {
+	s.mu.Lock()
+	s.passing = true
+	s.mu.Unlock()
+	rc.Close()
+	s.mu.Lock()
+	s.passing = false
+	s.mu.Unlock()
+}
+
+func (s *source) Next(fi muta.FileInfo, rc io.ReadCloser) (muta.FileInfo,
+	io.ReadCloser, error) {
+
+	if fi != nil {
+		return fi, rc, nil
+	}
+	if len(s.files) == 0 {
+		return nil, nil, nil
+	}
+
+	f := s.files[0]
+	s.files = s.files[1:]
+	r := &readCloser{Reader: bytes.NewReader(f.Content), src: s}
+	s.readers = append(s.readers, r)
+	return f.Info, r, nil
+}
+
+// readCloser records whether it has been closed by the Streamer, or
+// passed on by it.
+type readCloser struct {
+	io.Reader
+	src    *source
+	closed bool
+	passed bool
+}
+
+func (r *readCloser) Close() error {
+	r.src.mu.Lock()
+	defer r.src.mu.Unlock()
+	if r.src.passing {
+		r.passed = true
+	} else {
+		r.closed = true
+	}
+	return nil
+}
+
+// done returns true if the reader was closed or passed on.
+func (r *readCloser) done() bool {
+	r.src.mu.Lock()
+	defer r.src.mu.Unlock()
+	return r.closed || r.passed
+}
@@ -0,0 +1,164 @@
+package mtesting
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// upper uppercases the content of .txt files, renaming them to .TXT,
+// and passes on every other file.
+func upper() muta.Streamer {
+	return muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+		muta.FileInfo, io.ReadCloser, error) {
+		if fi == nil || !strings.HasSuffix(fi.Name(), ".txt") {
+			return fi, rc, nil
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, nil, err
+		}
+		fi.SetName(strings.TrimSuffix(fi.Name(), ".txt") + ".TXT")
+		return fi, mutil.ByteCloser([]byte(strings.ToUpper(string(b)))), nil
+	})
+}
+
+// closer records whether the Streamer was closed.
+type closer struct {
+	muta.Streamer
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	Convey("Should collect the files output by the Streamer", t, func() {
+		out, err := Run(upper(),
+			NewFile("a/b.txt", "foo"),
+			NewFile("c.md", "bar"))
+		So(err, ShouldBeNil)
+		So(out.Names(), ShouldResemble, []string{"b.TXT", "c.md"})
+		So(out.Paths(), ShouldResemble, []string{"a/b.TXT", "c.md"})
+		So(out.Contents(), ShouldResemble, []string{"FOO", "bar"})
+		So(out[0].Info.OriginalName(), ShouldEqual, "b.txt")
+	})
+
+	Convey("Should collect generated files", t, func() {
+		out, err := Run(muta.Stream{
+			&MockStreamer{Files: []string{"a.txt"}, Contents: []string{"a"}},
+			upper(),
+		}, NewFile("b.txt", "b"))
+		So(err, ShouldBeNil)
+		So(out, ShouldHavePaths, "b.TXT", "a.TXT")
+		So(out, ShouldHaveContents, "B", "A")
+	})
+
+	Convey("Should close the Streamer", t, func() {
+		c := &closer{Streamer: upper()}
+		_, err := Run(c, NewFile("a.txt", "a"))
+		So(err, ShouldBeNil)
+		So(c.closed, ShouldBeTrue)
+	})
+
+	Convey("Should return the error of the Streamer", t, func() {
+		_, err := Run(&MockStreamer{
+			Files:  []string{"a"},
+			Errors: []error{errors.New("foo")},
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAssertions(t *testing.T) {
+	out := Files{NewFile("a/b.txt", "foo"), NewFile("c.md", "bar")}
+
+	Convey("Should assert the names, paths and contents", t, func() {
+		So(ShouldHaveNames(out, "b.txt", "c.md"), ShouldEqual, "")
+		So(ShouldHaveNames(out, "b.txt"), ShouldNotEqual, "")
+		So(ShouldHavePaths([]File(out), "a/b.txt", "c.md"), ShouldEqual, "")
+		So(ShouldHavePaths(out[0], "a/b.txt"), ShouldEqual, "")
+		So(ShouldHaveContents(out, "foo", "bar"), ShouldEqual, "")
+		So(ShouldHaveContents(out, "bar", "foo"), ShouldNotEqual, "")
+		So(ShouldHaveNames("foo", "a"), ShouldNotEqual, "")
+	})
+
+	Convey("Should assert a single file", t, func() {
+		So(out, ShouldHaveFile, "a/b.txt")
+		So(out, ShouldHaveFile, "./c.md", "bar")
+		So(ShouldHaveFile(out, "c.md", "foo"), ShouldNotEqual, "")
+		So(ShouldHaveFile(out, "d.md"), ShouldNotEqual, "")
+		So(ShouldHaveFile(out), ShouldNotEqual, "")
+	})
+}
+
+func TestCheckContract(t *testing.T) {
+	Convey("Should pass a Streamer following the contract", t, func() {
+		err := CheckContract(upper, NewFile("a.txt", "a"))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Should catch Streamers not closing readers", t, func() {
+		leaky := muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+			muta.FileInfo, io.ReadCloser, error) {
+			if fi == nil || fi.Name() != "a.txt" {
+				return fi, rc, nil
+			}
+			return fi, mutil.StringCloser("new"), nil
+		})
+		err := CheckClosesReaders(leaky, NewFile("a.txt", "a"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring,
+			"a.txt was neither closed nor passed on")
+	})
+
+	Convey("Should tell readers passed on from closed ones", t, func() {
+		_, rcs, err := run(upper(), 0, []File{
+			NewFile("a.txt", "a"), NewFile("b.md", "b")})
+		So(err, ShouldBeNil)
+		So(rcs[0].closed, ShouldBeTrue)
+		So(rcs[0].passed, ShouldBeFalse)
+		So(rcs[1].closed, ShouldBeFalse)
+		So(rcs[1].passed, ShouldBeTrue)
+	})
+
+	Convey("Should catch Streamers dropping unknown files", t, func() {
+		drop := muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+			muta.FileInfo, io.ReadCloser, error) {
+			if rc != nil {
+				rc.Close()
+			}
+			return nil, nil, nil
+		})
+		err := CheckContract(func() muta.Streamer { return drop })
+		So(err, ShouldHaveSameTypeAs, &ContractError{})
+		So(err.(*ContractError).Broken, ShouldResemble, []string{
+			"the unknown file mtesting/unknown.mtesting-unknown was not " +
+				"passed on",
+		})
+	})
+
+	Convey("Should catch Streamers failing on nil input", t, func() {
+		nilPanic := muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+			muta.FileInfo, io.ReadCloser, error) {
+			fi.Name()
+			return fi, rc, nil
+		})
+		So(CheckNilInput(nilPanic), ShouldNotBeNil)
+
+		endless := muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+			muta.FileInfo, io.ReadCloser, error) {
+			return muta.NewFileInfo("a"), nil, nil
+		})
+		So(CheckNilInput(endless), ShouldNotBeNil)
+	})
+}
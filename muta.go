@@ -6,101 +6,557 @@
 package muta
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"runtime/pprof"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	"github.com/leeola/muta/logging"
 )
 
+// The Options section of the usage. This is kept separate so that the
+// shell completion can complete the flags.
+const usageOptions string = `Options:
+  -l=<level>  The log level, info by default. Tags can be given their
+              own level, as in info,muta.Dest=debug,markdown*=verbose
+  -t=<tags>   A comma separated list of logging tags
+  --log-format=<format>  The log format, text or json
+  --log-file=<file>  Also write the full, verbose log to file, in the
+              log format
+  --color=<when>  Color the output: auto, always or never. Auto colors
+              the output, and shows the progress of tasks, when it is a
+              terminal
+  -j=<jobs>   The number of tasks to run at once, 1 by default. Only
+              dependencies overlap, the tasks given still run in order
+  --list      List all tasks, with descriptions and dependencies
+  --graph     Show all tasks, their dependencies and Streams
+  --dot       Render the graph in the Graphviz DOT format
+  --plugins   List the registered Streamers, and their options
+  --trace     Trace every file through the Streams of the task
+  --json      Write the list, plugins, config or trace as JSON
+  --profile   Print a summary of the time spent in each Streamer
+  --cpuprofile=<file>  Write a pprof CPU profile of the run to file
+  --report=<file>  Write a JSON summary of the run to file
+  --config    Print the configuration, from the config file,
+              environment and flags
+  --completion=<shell>  Print a bash, zsh or fish completion script
+  --complete  Print completions for the given words, used by the scripts
+  -h --help   Show this screen.
+  --version   Show version.
+`
+
 // The dynamic use of task names makes this function a bit of a
-// clusterfuck. This needs to be cleaned up, along with the
-// taskNames logic below.
+// clusterfuck. This needs to be cleaned up.
 func ParseArgs(tasks []string) map[string]interface{} {
+	// Options must come before tasks, so that everything following the
+	// first task is left for the task Params.
+	args, _ := docopt.Parse(
+		usage(tasks), nil, true, fmt.Sprintf("Muta %s (lib)", VERSION), true,
+	)
+
+	return args
+}
+
+// Build the usage doc, listing the given task lines.
+func usage(tasks []string) string {
 	sTasks := ""
 	if tasks != nil && len(tasks) > 0 {
 		sTasks = fmt.Sprintf(`
 Tasks:
   %s
+
+  Task parameters follow the task, as name=value or --name value.
 `, strings.Join(tasks, "\n  "))
 	}
 
-	usage := fmt.Sprintf(`Muta(te)
+	return fmt.Sprintf(`Muta(te)
 
 Usage:
-  muta [-l=<level>] [-t=<tags>] [<task>]
+  muta [options] [<task>...]
+  muta [options] --trace [--json] <task>...
+  muta --list [--json]
+  muta --graph [--dot]
+  muta --plugins [--json]
+  muta [options] --config [--json]
+  muta --completion=<shell>
+  muta --complete [--] [<word>...]
   muta -h | --help
   muta --version
 %s
-Options:
-  -l=<level>  The log level [default: info]
-  -t=<tags>   A comma separated list of logging tags
-  -h --help   Show this screen.
-  --version   Show version.
-`, sTasks)
+%s`, sTasks, usageOptions)
+}
 
-	args, _ := docopt.Parse(
-		usage, nil, true, fmt.Sprintf("Muta %s (lib)", VERSION), false,
-	)
+// Run the Tasker as a command, with the given arguments (not including
+// the program name). Output is written to stdout, errors to stderr,
+// and the Tasker's Logger is configured from the logging flags. If the
+// Tasker has no Logger, or uses the default Logger of the logging
+// package, a Logger writing to stderr is used for the run.
+//
+// Main never exits the process, instead returning the exit code for
+// the run, so that it can be tested or embedded in another program.
+func (tr *Tasker) Main(args []string, stdout, stderr io.Writer) int {
+	// A nil argv makes Docopt read os.Args
+	if args == nil {
+		args = []string{}
+	}
+	args = tr.moveOptions(args)
 
-	return args
+	version := fmt.Sprintf("Muta %s (lib)", VERSION)
+	p := &docopt.Parser{
+		HelpHandler: func(err error, u string) {
+			if err != nil {
+				// Docopt only explains some of its errors
+				msg := err.Error()
+				if msg == "" {
+					msg = fmt.Sprintf("Invalid arguments \"%s\"",
+						strings.Join(args, " "))
+				}
+				fmt.Fprintln(stderr, "Error:", msg)
+				fmt.Fprintln(stderr, u)
+			} else if u == version {
+				fmt.Fprintln(stdout, u)
+			} else {
+				fmt.Fprintln(stdout, usage(tr.helpTasks()))
+			}
+		},
+		// Everything following the first task is left for the task
+		// Params. Options given after a task are moved before it by
+		// moveOptions.
+		OptionsFirst: true,
+	}
+	// The tasks are only listed in the help, since Docopt would parse
+	// their descriptions as part of the usage
+	opts, err := p.ParseArgs(usage(nil), args, version)
+	if err != nil {
+		return ExitValidation
+	}
+	// Help and version were requested, and have already been printed
+	if opts == nil {
+		return ExitOK
+	}
+
+	if shell, ok := opts["--completion"].(string); ok {
+		if err := WriteCompletion(stdout, shell); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return ExitValidation
+		}
+		return ExitOK
+	}
+
+	if opts["--complete"] == true {
+		words, _ := opts["<word>"].([]string)
+		for _, c := range tr.Complete(words) {
+			fmt.Fprintln(stdout, c)
+		}
+		return ExitOK
+	}
+
+	if opts["--list"] == true {
+		if opts["--json"] == true {
+			tr.WriteListJSON(stdout)
+		} else {
+			tr.WriteList(stdout)
+		}
+		return ExitOK
+	}
+
+	if opts["--graph"] == true {
+		if opts["--dot"] == true {
+			tr.WriteGraphDOT(stdout)
+		} else {
+			tr.WriteGraph(stdout)
+		}
+		return ExitOK
+	}
+
+	if opts["--plugins"] == true {
+		if opts["--json"] == true {
+			tr.registry().WriteListJSON(stdout)
+		} else {
+			tr.registry().WriteList(stdout)
+		}
+		return ExitOK
+	}
+
+	// The report is written whatever the outcome, to record failures
+	// before any task ran too
+	start := time.Now()
+	report := func(p string, results []TaskResult, code int, err error) int {
+		if p != "" {
+			r := NewReport(results, time.Since(start), err)
+			r.ExitCode = code
+			if rErr := writeReport(p, r); rErr != nil {
+				fmt.Fprintln(stderr, "Error: Unable to write report:", rErr)
+			}
+		}
+		return code
+	}
+
+	// Flags override the Config of the Tasker
+	fc, err := flagsConfig(opts)
+	cfg := DefaultConfig().Merge(tr.Config).Merge(fc)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return report(cfg.Report, nil, ExitValidation, err)
+	}
+
+	if opts["--config"] == true {
+		if opts["--json"] == true {
+			cfg.WriteJSON(stdout)
+		} else {
+			cfg.WriteYAML(stdout)
+		}
+		return ExitOK
+	}
+
+	results, code, err := tr.run(opts, cfg, stdout, stderr)
+	return report(cfg.Report, results, code, err)
+}
+
+// run configures the Tasker from the Config and runs the tasks given
+// in the options, as Main does, returning the results of the tasks,
+// the exit code and the error of the run, if any. Errors are printed
+// to stderr.
+func (tr *Tasker) run(opts map[string]interface{}, cfg Config, stdout,
+	stderr io.Writer) ([]TaskResult, int, error) {
+
+	fail := func(code int, msg string, err error) ([]TaskResult, int,
+		error) {
+		fmt.Fprintln(stderr, msg, err)
+		return nil, code, err
+	}
+
+	// The default Logger of the logging package is shared by the whole
+	// process, so the run gets its own instead of changing it
+	if tr.Logger == nil || tr.Logger == logging.DefaultLogger() {
+		l := tr.Logger
+		tr.Logger = logging.NewLogger(stderr)
+		defer func() { tr.Logger = l }()
+	}
+	if err := tr.Logger.SetTags(cfg.Tags...); err != nil {
+		return fail(ExitValidation, "Error:", err)
+	}
+	if err := tr.Logger.SetLevels(cfg.LogLevel); err != nil {
+		return fail(ExitValidation, "Error:", err)
+	}
+	lf, err := logging.FormatterFromString(cfg.LogFormat)
+	if err != nil {
+		return fail(ExitValidation, "Error:", err)
+	}
+	// The log file is never colored, even if the console is
+	fileFormat := lf
+	color := useColor(cfg.Color, stderr)
+	if _, ok := lf.(logging.TextFormatter); ok {
+		lf = logging.TextFormatter{Color: color}
+
+		// Progress lines are only drawn on a terminal, and are moved out
+		// of the way of the logs, and of what tasks print to it.
+		if tr.Progress == nil && showProgress(color, stderr) {
+			tr.Progress = NewProgress(stderr)
+			tr.Progress.Color = color
+			w := tr.Logger.Writer()
+			tr.Logger.SetWriter(tr.Progress.Writer(w))
+			defer tr.Logger.SetWriter(w)
+
+			if stdout == os.Stdout && sameFile(stdout, stderr) {
+				if restore, err := tr.Progress.Stdout(); err == nil {
+					defer restore()
+				}
+			}
+		}
+	}
+	tr.Logger.SetFormatter(lf)
+
+	if cfg.LogFile != "" {
+		f, err := os.Create(cfg.LogFile)
+		if err != nil {
+			return fail(ExitTaskFailed, "Error: Unable to create log file:",
+				err)
+		}
+		defer f.Close()
+
+		sink := logging.NewLogger(f)
+		sink.SetLevel(logging.VERBOSE)
+		sink.SetFormatter(fileFormat)
+		tr.Logger.AddSink(sink)
+		defer tr.Logger.RemoveSink(sink)
+	}
+	tr.Jobs = cfg.Jobs
+
+	if opts["--trace"] == true {
+		tr.Tracer = NewTracer()
+	}
+
+	if opts["--profile"] == true {
+		tr.Profiler = NewProfiler()
+	}
+
+	// Task names and their Params are both in "<task>", in the order
+	// they were given. Don't think Docopt will return anything but a
+	// string slice.
+	taskArgs, _ := opts["<task>"].([]string)
+	names, params, err := tr.ParseTaskArgs(taskArgs)
+	if err != nil {
+		return fail(ExitCode(err), "Error:", err)
+	}
+	if len(names) == 0 {
+		names = []string{cfg.Default}
+	}
+
+	if p, ok := opts["--cpuprofile"].(string); ok {
+		f, err := os.Create(p)
+		if err != nil {
+			return fail(ExitTaskFailed, "Error:", err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fail(ExitTaskFailed, "Error:", err)
+		}
+	}
+
+	results, err := tr.RunTasksWithParams(names, params)
+
+	if opts["--cpuprofile"] != nil {
+		pprof.StopCPUProfile()
+	}
+
+	if tr.Profiler != nil {
+		tr.Profiler.WriteSummary(stdout)
+	}
+
+	if tr.Tracer != nil {
+		if opts["--json"] == true {
+			tr.Tracer.WriteJSON(stdout)
+		} else {
+			tr.Tracer.WriteText(stdout)
+		}
+	}
+
+	// The summary is kept out of stdout, which tasks may write to
+	if len(results) > 0 {
+		fmt.Fprintln(stderr, "Summary:")
+		WriteTaskSummary(stderr, results)
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return results, ExitCode(err), err
+	}
+	return results, ExitOK, nil
 }
 
+// Run the DefaultTasker with the process arguments, exiting with a non
+// zero code if the run failed. The defaults of the options are loaded
+// from the config file in the current directory and MUTA_* environment
+// variables, see LoadConfig() and Config.ApplyEnv(). Tasks declared in
+// a PipelineFile in the current directory are added to the
+// DefaultTasker.
+//
+// The first interrupt stops the run after the current file, killing any
+// running Commands, the second exits immediately.
 func Te() {
-	// TODO: This taskNames logic needs to be separated and improved.
-	// I'm thinking, it's own func, and return a formatted docopt friendly
-	// string
-	taskNames := []string{}
-	defaultTaskNames := []string{}
-	if DefaultTasker.Tasks["default"] != nil {
-		defaultTaskNames = DefaultTasker.Tasks["default"].Dependencies
-	}
-	for tn := range DefaultTasker.Tasks {
-		if tn == "default" {
+	cfg, err := LoadConfig(".")
+	if err == nil {
+		cfg, err = DefaultTasker.Config.Merge(cfg).ApplyEnv(os.Environ())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(ExitCode(err))
+	}
+	DefaultTasker.Config = cfg
+
+	err = DefaultTasker.LoadPipelines(PipelineFile)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(ExitCode(err))
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		DefaultTasker.Interrupt()
+		<-sigs
+		// Don't leave the processes of Commands running
+		DefaultTasker.killCommands()
+		os.Exit(ExitInterrupted)
+	}()
+
+	code := DefaultTasker.Main(os.Args[1:], os.Stdout, os.Stderr)
+	signal.Stop(sigs)
+
+	if code != ExitOK {
+		os.Exit(code)
+	}
+}
+
+// Return the Config given by the flags, leaving options which were not
+// given empty.
+func flagsConfig(opts map[string]interface{}) (Config, error) {
+	var c Config
+	// Don't think Docopt will return anything but strings. The report
+	// is first, so that it is known even if other flags are invalid.
+	if r, ok := opts["--report"].(string); ok {
+		c.Report = r
+	}
+	if l, ok := opts["-l"].(string); ok {
+		c.LogLevel = l
+	}
+	if f, ok := opts["--log-format"].(string); ok {
+		c.LogFormat = f
+	}
+	if lf, ok := opts["--log-file"].(string); ok {
+		c.LogFile = lf
+	}
+	if cl, ok := opts["--color"].(string); ok {
+		c.Color = cl
+	}
+	if t, ok := opts["-t"].(string); ok {
+		c.Tags = splitTags(t)
+	}
+	if j, ok := opts["-j"].(string); ok {
+		n, err := strconv.Atoi(j)
+		if err != nil || n < 1 {
+			return c, errors.New(fmt.Sprintf(
+				"-j must be a positive number, got '%s'", j))
+		}
+		c.Jobs = n
+	}
+	return c, c.check()
+}
+
+// Return the arguments with any options given after the first task,
+// such as `muta build -l debug`, moved before the tasks, since Docopt
+// leaves everything after the first task for the task Params. A Param
+// declared by the preceding task takes precedence over an option of
+// the same name. Everything after "--" is left in place.
+func (tr *Tasker) moveOptions(args []string) []string {
+	opts := cliOptions()
+
+	// Skip the options already before the first task
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || a == "--complete" {
+			return args
+		}
+		if !strings.HasPrefix(a, "-") {
+			break
+		}
+		if opts[a] {
+			i++
+		}
+	}
+	if i > len(args) {
+		// The last option is missing its argument, left for Docopt
+		return args
+	}
+
+	moved := append([]string{}, args[:i]...)
+	var rest []string
+	var t *TaskerTask
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			if wt := tr.Tasks[a]; wt != nil {
+				t = wt
+			}
+			rest = append(rest, a)
 			continue
 		}
-		// A dirty implementation of default appending.
-		for _, dtn := range defaultTaskNames {
-			// If the taskName is a dependency of the Default Task, append
-			// " (default)" to the taskName
-			if tn == dtn {
-				tn += " (default)"
-				break
+
+		n := a
+		hasValue := false
+		if j := strings.Index(a, "="); j > -1 {
+			n, hasValue = a[:j], true
+		}
+		if t != nil && strings.HasPrefix(n, "--") {
+			if p, ok := t.param(strings.TrimPrefix(n, "--")); ok {
+				rest = append(rest, a)
+				if !hasValue && i+1 < len(args) && (p.Kind != BoolParamKind ||
+					tr.isBoolArg(args[i+1])) {
+					i++
+					rest = append(rest, args[i])
+				}
+				continue
 			}
 		}
-		taskNames = append(taskNames, tn)
-	}
-	args := ParseArgs(taskNames)
 
-	// Set logging tags
-	if args["-t"] != nil {
-		// Don't think Docopt will return anything but a string
-		tags, _ := args["-t"].(string)
-		logging.SetTags(strings.Split(tags, ",")...)
-	} else {
-		logging.SetTags()
+		// Short options may have their argument attached, as in -ldebug
+		hasArg, ok := opts[n]
+		if !ok && !strings.HasPrefix(n, "--") && len(n) > 2 {
+			n, hasValue = n[:2], true
+			hasArg, ok = opts[n]
+		}
+		if !ok {
+			rest = append(rest, a)
+			continue
+		}
+		moved = append(moved, a)
+		if hasArg && !hasValue && i+1 < len(args) {
+			i++
+			moved = append(moved, args[i])
+		}
 	}
+	return append(moved, rest...)
+}
+
+// Return whether the progress of tasks is drawn on stderr, which is
+// only when it is a colored terminal.
+func showProgress(color bool, stderr io.Writer) bool {
+	return color && logging.IsTerminal(stderr)
+}
 
-	// Set logging level
-	// (it has a default, so it should never be nil)
-	// Don't think Docopt will return anything but a string
-	logLevel, _ := args["-l"].(string)
-	logging.SetLevel(logging.LevelFromString(logLevel))
+// Return true if both writers are the same file, such as when stdout
+// and stderr are the same terminal.
+func sameFile(a, b io.Writer) bool {
+	fa, ok := a.(*os.File)
+	if !ok {
+		return false
+	}
+	fb, ok := b.(*os.File)
+	if !ok {
+		return false
+	}
+	sa, err := fa.Stat()
+	if err != nil {
+		return false
+	}
+	sb, err := fb.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
 
-	var err error
-	if args["<task>"] == nil {
-		err = DefaultTasker.Run()
-	} else {
-		// Don't think Docopt will return anything but a string
-		name, _ := args["<task>"].(string)
-		err = DefaultTasker.RunTask(name)
+// Return whether output to w is colored, when given a Config.Color.
+func useColor(when string, w io.Writer) bool {
+	switch when {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
 	}
+	return logging.IsTerminal(w)
+}
 
+func writeReport(p string, r Report) error {
+	f, err := os.Create(p)
 	if err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+		return err
 	}
+	defer f.Close()
+	return r.WriteJSON(f)
 }
 
 // An alias for Te()
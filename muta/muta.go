@@ -5,6 +5,10 @@
 // finding and running the specified `muta.go` file in the current/target
 // directory. Though that logic is actually handled by GoScriptify.
 //
+// The bin also handles `muta init [template]`, which scaffolds a new
+// project in the current directory, and runs a `muta.yaml` pipeline
+// file directly if there is no `muta.go`.
+//
 package main
 
 import (
@@ -13,6 +17,10 @@ import (
 
 	"github.com/leeola/goscriptify"
 	"github.com/leeola/muta"
+	"github.com/leeola/muta/scaffold"
+
+	// Register the plugin Streamer, for pipeline files
+	_ "github.com/leeola/muta/mplugin"
 )
 
 func main() {
@@ -20,8 +28,62 @@ func main() {
 		fmt.Println(fmt.Sprintf("Muta %s (bin)", muta.VERSION))
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		os.Exit(initProject(os.Args[2:]))
+	}
+
 	// Proxy this bin input/output to the "muta" file
 	// in the current directory
-	goscriptify.RunOneScriptOrDir(true,
-		"muta", "Muta", "muta.go", "muta/muta.go", ".muta/muta.go")
+	scripts := []string{
+		"muta", "Muta", "muta.go", "muta/muta.go", ".muta/muta.go"}
+
+	// Without a muta script, run the pipeline file directly. Only the
+	// Streamers built into the bin are available to it.
+	if !anyExist(scripts) && anyExist([]string{muta.PipelineFile}) {
+		muta.Te()
+		return
+	}
+
+	goscriptify.RunOneScriptOrDir(true, scripts...)
+}
+
+// Return true if any of the given paths exist.
+func anyExist(ps []string) bool {
+	for _, p := range ps {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Scaffold the given template, or the default, into the current
+// directory.
+func initProject(args []string) int {
+	name := scaffold.DefaultTemplate
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	if name == "-h" || name == "--help" || len(args) > 1 {
+		fmt.Println("Usage:\n  muta init [<template>]\n\nTemplates:")
+		for _, t := range scaffold.Templates() {
+			fmt.Printf("  %-10s %s\n", t.Name, t.Description)
+		}
+		if len(args) > 1 {
+			return muta.ExitValidation
+		}
+		return muta.ExitOK
+	}
+
+	ps, err := scaffold.Init(".", name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return muta.ExitValidation
+	}
+	for _, p := range ps {
+		fmt.Println("Created", p)
+	}
+	fmt.Println("\nRun \"muta -h\" to list the tasks.")
+	return muta.ExitOK
 }
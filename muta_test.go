@@ -0,0 +1,409 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leeola/muta/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Return what was logged to b, without the summary of the run.
+func logged(b *bytes.Buffer) string {
+	s := b.String()
+	if i := strings.Index(s, "Summary:\n"); i > -1 {
+		return s[:i]
+	}
+	return s
+}
+
+func TestTaskerMoveOptions(t *testing.T) {
+	var got Params
+	ta := newParamsTasker(&got)
+	ta.Task("json", BoolParam("json", false, ""), func() {})
+
+	Convey("Should move options after tasks before them", t, func() {
+		So(ta.moveOptions([]string{"build", "-l", "debug", "deploy", "--trace"}),
+			ShouldResemble, []string{"-l", "debug", "--trace", "build", "deploy"})
+		So(ta.moveOptions([]string{"-t", "a", "build", "-ldebug", "--color=never"}),
+			ShouldResemble, []string{"-t", "a", "-ldebug", "--color=never", "build"})
+	})
+
+	Convey("Should leave options before tasks in place", t, func() {
+		args := []string{"-l", "debug", "-t", "a", "build", "deploy"}
+		So(ta.moveOptions(args), ShouldResemble, args)
+	})
+
+	Convey("Should leave task Params in place", t, func() {
+		So(ta.moveOptions([]string{"deploy", "--env", "prod", "-t", "a",
+			"--dry-run", "false"}), ShouldResemble, []string{"-t", "a",
+			"deploy", "--env", "prod", "--dry-run", "false"})
+		So(ta.moveOptions([]string{"json", "--json", "build", "--json"}),
+			ShouldResemble, []string{"--json", "json", "--json", "build"})
+	})
+
+	Convey("Should leave an option missing its argument in place", t, func() {
+		So(ta.moveOptions([]string{"-t"}), ShouldResemble, []string{"-t"})
+		So(ta.moveOptions([]string{"build", "-t"}), ShouldResemble,
+			[]string{"-t", "build"})
+	})
+
+	Convey("Should leave everything after -- in place", t, func() {
+		args := []string{"--complete", "--", "build", "-l"}
+		So(ta.moveOptions(args), ShouldResemble, args)
+		So(ta.moveOptions([]string{"build", "--", "-l"}), ShouldResemble,
+			[]string{"build", "--", "-l"})
+	})
+}
+
+func TestShowProgress(t *testing.T) {
+	Convey("Should not show progress without a colored terminal", t, func() {
+		var b bytes.Buffer
+		So(showProgress(true, &b), ShouldBeFalse)
+		So(showProgress(false, os.Stderr), ShouldBeFalse)
+	})
+
+	Convey("Should tell when writers are the same file", t, func() {
+		f, err := ioutil.TempFile("", "muta-progress")
+		So(err, ShouldBeNil)
+		defer os.Remove(f.Name())
+		defer f.Close()
+		f2, err := os.Open(f.Name())
+		So(err, ShouldBeNil)
+		defer f2.Close()
+		g, err := ioutil.TempFile("", "muta-progress")
+		So(err, ShouldBeNil)
+		defer os.Remove(g.Name())
+		defer g.Close()
+
+		So(sameFile(f, f2), ShouldBeTrue)
+		So(sameFile(f, g), ShouldBeFalse)
+		So(sameFile(f, &bytes.Buffer{}), ShouldBeFalse)
+	})
+}
+
+func TestTaskerMain(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	newTasker := func() *Tasker {
+		stdout.Reset()
+		stderr.Reset()
+		ta := NewTasker()
+		ta.Logger = logging.NewLogger(&stderr)
+		return ta
+	}
+
+	Convey("Should run the given tasks", t, func() {
+		ran := []string{}
+		ta := newTasker()
+		ta.Task("a", func() { ran = append(ran, "a") })
+		ta.Task("b", "a", func() { ran = append(ran, "b") })
+		So(ta.Main([]string{"b"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(ran, ShouldResemble, []string{"a", "b"})
+		So(stderr.String(), ShouldContainSubstring, "Summary:")
+		So(stdout.String(), ShouldNotContainSubstring, "Summary:")
+	})
+
+	Convey("Should run the default task without arguments", t, func() {
+		ran := false
+		ta := newTasker()
+		ta.Task("default", func() { ran = true })
+		So(ta.Main(nil, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(ran, ShouldBeTrue)
+	})
+
+	Convey("Should pass task parameters", t, func() {
+		var name string
+		ta := newTasker()
+		ta.Task("a", StringParam("name", "", ""), func(ps Params) error {
+			name = ps.String("name")
+			return nil
+		})
+		So(ta.Main([]string{"a", "name=foo"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		So(name, ShouldEqual, "foo")
+	})
+
+	Convey("Should accept options before or after the tasks", t, func() {
+		for _, args := range [][]string{
+			{"-l", "warn", "a", "--dry-run", "false"},
+			{"a", "--dry-run", "false", "-l", "warn"},
+			{"a", "-l", "warn", "--dry-run=false"},
+		} {
+			dryRun := true
+			stderr.Reset()
+			ta := newTasker()
+			ta.Task("a", BoolParam("dry-run", true, ""), func(ps Params) error {
+				dryRun = ps.Bool("dry-run")
+				ta.Logger.Info(nil, "info")
+				return nil
+			})
+			So(ta.Main(args, &stdout, &stderr), ShouldEqual, ExitOK)
+			So(dryRun, ShouldBeFalse)
+			So(stderr.String(), ShouldNotContainSubstring, "info")
+		}
+	})
+
+	Convey("Should write the --report whatever the outcome", t, func() {
+		dir, err := ioutil.TempDir("", "muta-report")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		p := filepath.Join(dir, "report.json")
+
+		read := func() Report {
+			var r Report
+			b, err := ioutil.ReadFile(p)
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(b, &r), ShouldBeNil)
+			return r
+		}
+
+		ta := newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"--report", p, "a"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		r := read()
+		So(r.ExitCode, ShouldEqual, ExitOK)
+		So(len(r.Tasks), ShouldEqual, 1)
+
+		So(ta.Main([]string{"--report", p, "nope"}, &stdout, &stderr),
+			ShouldEqual, ExitTaskNotFound)
+		r = read()
+		So(r.ExitCode, ShouldEqual, ExitTaskNotFound)
+		So(r.Error, ShouldContainSubstring, "nope")
+
+		So(ta.Main([]string{"--report", p, "-l", "foo", "a"}, &stdout,
+			&stderr), ShouldEqual, ExitValidation)
+		So(read().ExitCode, ShouldEqual, ExitValidation)
+	})
+
+	Convey("Should log with the Tasker's Logger", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"a"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stderr.String(), ShouldContainSubstring, "[Task] a starting")
+
+		ta = newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"-l", "error", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		So(logged(&stderr), ShouldEqual, "")
+	})
+
+	Convey("Should return the exit code of a failed run", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() error { return errors.New("foo") })
+		So(ta.Main([]string{"a"}, &stdout, &stderr),
+			ShouldEqual, ExitTaskFailed)
+		So(stderr.String(), ShouldContainSubstring, "Error: foo")
+
+		ta = newTasker()
+		So(ta.Main([]string{"b"}, &stdout, &stderr),
+			ShouldEqual, ExitTaskNotFound)
+	})
+
+	Convey("Should print usage errors to stderr", t, func() {
+		ta := newTasker()
+		So(ta.Main([]string{"--foo"}, &stdout, &stderr),
+			ShouldEqual, ExitValidation)
+		So(stderr.String(), ShouldContainSubstring,
+			"Error: Invalid arguments \"--foo\"")
+		So(stderr.String(), ShouldContainSubstring, "Usage:")
+		So(stdout.String(), ShouldEqual, "")
+	})
+
+	Convey("Should not change the default Logger", t, func() {
+		var global bytes.Buffer
+		dl := logging.DefaultLogger()
+		w := dl.Writer()
+		dl.SetWriter(&global)
+		defer dl.SetWriter(w)
+
+		stderr.Reset()
+		ta := NewTasker()
+		ta.Task("a", func() {})
+		So(ta.Logger, ShouldEqual, dl)
+		So(ta.Main([]string{"-l", "debug", "--log-format=json", "a"}, &stdout,
+			&stderr), ShouldEqual, ExitOK)
+		So(stderr.String(), ShouldContainSubstring, `"msg":"a starting"`)
+		So(global.String(), ShouldEqual, "")
+		So(ta.Logger, ShouldEqual, dl)
+
+		// Still at the level of the tests, and in text
+		dl.Error(nil, "after")
+		dl.Debug(nil, "debug")
+		So(global.String(), ShouldEqual, "after\n")
+	})
+
+	Convey("Should print help and version without exiting", t, func() {
+		ta := newTasker()
+		ta.Task("a", Desc("Does a"), func() {})
+		So(ta.Main([]string{"--help"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stdout.String(), ShouldContainSubstring, "Usage:")
+		So(stdout.String(), ShouldContainSubstring, "Does a")
+
+		stdout.Reset()
+		ta.Task("b", Desc("Usage: b\nOptions: none"), func() {})
+		So(ta.Main([]string{"b"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(ta.Main([]string{"-h"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stdout.String(), ShouldContainSubstring, "Usage: b")
+
+		stdout.Reset()
+		So(ta.Main([]string{"--version"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		So(strings.TrimSpace(stdout.String()), ShouldEqual,
+			"Muta "+VERSION+" (lib)")
+	})
+
+	Convey("Should list tasks to stdout", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"--list"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stdout.String(), ShouldContainSubstring, "a")
+	})
+
+	Convey("Should use the Tasker's Config", t, func() {
+		ran := false
+		ta := newTasker()
+		ta.Config = Config{LogLevel: "error", Default: "a", Jobs: 2}
+		ta.Task("a", func() { ran = true })
+		So(ta.Main(nil, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(ran, ShouldBeTrue)
+		So(ta.Jobs, ShouldEqual, 2)
+		So(logged(&stderr), ShouldEqual, "")
+	})
+
+	Convey("Should override the Config with flags", t, func() {
+		ta := newTasker()
+		ta.Config = Config{LogLevel: "error", Jobs: 2}
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"-l", "info", "-j", "3", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		So(ta.Jobs, ShouldEqual, 3)
+		So(stderr.String(), ShouldContainSubstring, "[Task] a starting")
+
+		So(ta.Main([]string{"-j", "foo", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitValidation)
+	})
+
+	Convey("Should set the level of tags", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() {
+			ta.Logger.Debug([]string{"muta.Dest"}, "dest")
+			ta.Logger.Debug([]string{"muta.Src"}, "src")
+		})
+		So(ta.Main([]string{"-l", "warn,muta.Dest=debug", "a"}, &stdout,
+			&stderr), ShouldEqual, ExitOK)
+		So(logged(&stderr), ShouldEqual, "[muta.Dest] dest\n")
+
+		So(ta.Main([]string{"-l", "info,muta.Dest=foo", "a"}, &stdout,
+			&stderr), ShouldEqual, ExitValidation)
+	})
+
+	Convey("Should color the logs with --color", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"--color=always", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		So(stderr.String(), ShouldContainSubstring,
+			logging.ColorCyan+"[Task]"+logging.ColorReset+" a starting")
+
+		stderr.Reset()
+		So(ta.Main([]string{"a"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stderr.String(), ShouldContainSubstring, "[Task] a starting")
+		So(ta.Progress, ShouldBeNil)
+
+		So(ta.Main([]string{"--color=foo", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitValidation)
+	})
+
+	Convey("Should write the full log to the --log-file", t, func() {
+		dir, err := ioutil.TempDir("", "muta-log")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		p := filepath.Join(dir, "build.log")
+
+		ta := newTasker()
+		ta.Task("a", func() {
+			ta.Logger.Verbose([]string{"foo"}, "verbose")
+		})
+		So(ta.Main([]string{"-l", "warn", "--color=always", "--log-file", p,
+			"a"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(logged(&stderr), ShouldEqual, "")
+		b, err := ioutil.ReadFile(p)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual,
+			"[Task] a starting\n[foo] verbose\n[Task] a complete\n")
+
+		// The sink is removed once Main returns
+		ta.Logger.Warn(nil, "after")
+		b, _ = ioutil.ReadFile(p)
+		So(string(b), ShouldNotContainSubstring, "after")
+
+		So(ta.Main([]string{"--log-file", dir, "a"}, &stdout, &stderr),
+			ShouldEqual, ExitTaskFailed)
+	})
+
+	Convey("Should log in the --log-format", t, func() {
+		ta := newTasker()
+		ta.Task("a", func() {})
+		So(ta.Main([]string{"--log-format=json", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		line := strings.SplitN(stderr.String(), "\n", 2)[0]
+		var e map[string]interface{}
+		So(json.Unmarshal([]byte(line), &e), ShouldBeNil)
+		So(e["msg"], ShouldEqual, "a starting")
+		So(e["tags"], ShouldResemble, []interface{}{"Task"})
+
+		So(ta.Main([]string{"--log-format=xml", "a"}, &stdout, &stderr),
+			ShouldEqual, ExitValidation)
+	})
+
+	Convey("Should not clean a Dest when showing the --graph", t, func() {
+		dir, err := ioutil.TempDir("", "muta-graph")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		keep := filepath.Join(dir, "keep")
+		ioutil.WriteFile(keep, []byte("keep"), 0644)
+
+		ta := newTasker()
+		ta.Task("a", func() Stream {
+			return Stream{}.Pipe(DestWithOpts(dir, DestOpts{Clean: true}))
+		})
+		So(ta.Main([]string{"--graph"}, &stdout, &stderr), ShouldEqual, ExitOK)
+		So(stdout.String(), ShouldContainSubstring, "muta.Dest")
+		_, err = os.Stat(keep)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Should print the effective Config", t, func() {
+		ta := newTasker()
+		ta.Config = Config{Default: "build"}
+		So(ta.Main([]string{"-t", "foo", "--config"}, &stdout, &stderr),
+			ShouldEqual, ExitOK)
+		c, err := ParseConfig(".muta.yaml", stdout.Bytes())
+		So(err, ShouldBeNil)
+		So(c, ShouldResemble, Config{
+			LogLevel:  "info",
+			LogFormat: "text",
+			Color:     "auto",
+			Tags:      []string{"foo"},
+			Jobs:      1,
+			Default:   "build",
+		})
+	})
+
+	Convey("Should list the registered Streamers", t, func() {
+		ta := newTasker()
+		ta.Registry = NewRegistry()
+		ta.Registry.RegisterStreamer("foo", "Does foo", nil)
+		So(ta.Main([]string{"--plugins"}, &stdout, &stderr), ShouldEqual,
+			ExitOK)
+		So(stdout.String(), ShouldEqual, "foo  Does foo\n")
+	})
+}
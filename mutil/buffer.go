@@ -0,0 +1,88 @@
+package mutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// NewSpillBuffer reads all of the given io.Reader into a re-readable
+// buffer. Content up to max bytes is kept in memory, anything larger is
+// spilled to a temporary file, which is removed on Close.
+func NewSpillBuffer(r io.Reader, max int64) (*SpillBuffer, error) {
+	var mem bytes.Buffer
+	n, err := io.CopyN(&mem, r, max+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// If the reader fit within max, there is no need for a file.
+	if n <= max {
+		return &SpillBuffer{
+			ReadSeeker: bytes.NewReader(mem.Bytes()),
+			size:       n,
+		}, nil
+	}
+
+	f, err := ioutil.TempFile("", "muta-buffer-")
+	if err != nil {
+		return nil, err
+	}
+	b := &SpillBuffer{ReadSeeker: f, file: f}
+
+	if _, err := mem.WriteTo(f); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if b.size, err = f.Seek(0, io.SeekCurrent); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if err := b.Rewind(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// A SpillBuffer is a seekable, re-readable copy of some content. See
+// NewSpillBuffer() for details.
+type SpillBuffer struct {
+	io.ReadSeeker
+	file *os.File
+	size int64
+}
+
+// Rewind seeks back to the start of the content.
+func (b *SpillBuffer) Rewind() error {
+	_, err := b.Seek(0, io.SeekStart)
+	return err
+}
+
+// Size returns the total size of the content in bytes.
+func (b *SpillBuffer) Size() int64 {
+	return b.size
+}
+
+// Spilled returns true if the content was spilled to a temporary file.
+func (b *SpillBuffer) Spilled() bool {
+	return b.file != nil
+}
+
+// Close removes the temporary file, if any.
+func (b *SpillBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if rmErr := os.Remove(b.file.Name()); err == nil {
+		err = rmErr
+	}
+	b.file = nil
+	return err
+}
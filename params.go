@@ -0,0 +1,240 @@
+package muta
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParamKind is the type of value a Param accepts.
+type ParamKind string
+
+const (
+	StringParamKind ParamKind = "string"
+	BoolParamKind   ParamKind = "bool"
+	IntParamKind    ParamKind = "int"
+)
+
+// A Param declares a named, typed parameter of a task. Params are given
+// to Task() alongside the task's handler, and are set from the command
+// line with `muta <task> name=value` or `muta <task> --name value`.
+type Param struct {
+	Name    string      `json:"name"`
+	Kind    ParamKind   `json:"kind"`
+	Default interface{} `json:"default"`
+	Help    string      `json:"help,omitempty"`
+}
+
+// StringParam returns a string Param, with the given default and help.
+func StringParam(name, def, help string) Param {
+	return Param{Name: name, Kind: StringParamKind, Default: def, Help: help}
+}
+
+// BoolParam returns a bool Param, with the given default and help.
+func BoolParam(name string, def bool, help string) Param {
+	return Param{Name: name, Kind: BoolParamKind, Default: def, Help: help}
+}
+
+// IntParam returns an int Param, with the given default and help.
+func IntParam(name string, def int, help string) Param {
+	return Param{Name: name, Kind: IntParamKind, Default: def, Help: help}
+}
+
+// Parse converts the given string into a value of this Param's kind.
+func (p Param) Parse(s string) (interface{}, error) {
+	switch p.Kind {
+	case BoolParamKind:
+		return strconv.ParseBool(s)
+	case IntParamKind:
+		return strconv.Atoi(s)
+	}
+	return s, nil
+}
+
+// check returns an error if the given value is not of this Param's kind.
+func (p Param) check(v interface{}) error {
+	var ok bool
+	switch p.Kind {
+	case StringParamKind:
+		_, ok = v.(string)
+	case BoolParamKind:
+		_, ok = v.(bool)
+	case IntParamKind:
+		_, ok = v.(int)
+	}
+	if !ok {
+		return errors.New(fmt.Sprintf("expected a %s, got %T", p.Kind, v))
+	}
+	return nil
+}
+
+// Params holds the values of a task's declared Params, by name. Any
+// Param not given on the command line is set to its default.
+type Params map[string]interface{}
+
+// String returns the value of the named string Param.
+func (ps Params) String(n string) string {
+	s, _ := ps[n].(string)
+	return s
+}
+
+// Bool returns the value of the named bool Param.
+func (ps Params) Bool(n string) bool {
+	b, _ := ps[n].(bool)
+	return b
+}
+
+// Int returns the value of the named int Param.
+func (ps Params) Int(n string) int {
+	i, _ := ps[n].(int)
+	return i
+}
+
+// ParamError is returned when a task is given a Param it does not
+// declare, or a value that does not match the Param's kind.
+type ParamError struct {
+	Task  string
+	Param string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	if e.Task == "" {
+		return fmt.Sprintf("Parameter \"%s\": %s", e.Param, e.Err)
+	}
+	return fmt.Sprintf("Task \"%s\" parameter \"%s\": %s",
+		e.Task, e.Param, e.Err)
+}
+
+// param returns the named Param declared by this task, if any.
+func (t *TaskerTask) param(n string) (Param, bool) {
+	for _, p := range t.Params {
+		if p.Name == n {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// params returns the given Params merged over the defaults of every
+// Param this task declares.
+func (t *TaskerTask) params(given Params) Params {
+	ps := make(Params, len(t.Params))
+	for _, p := range t.Params {
+		ps[p.Name] = p.Default
+	}
+	for n, v := range given {
+		ps[n] = v
+	}
+	return ps
+}
+
+// validateParams returns an error if any of the given Params are not
+// declared by their task, or are of the wrong kind.
+func (tr *Tasker) validateParams(ps map[string]Params) error {
+	for tn, tps := range ps {
+		t := tr.Tasks[tn]
+		if t == nil {
+			return &TaskNotFoundError{tn}
+		}
+		for n, v := range tps {
+			p, ok := t.param(n)
+			if !ok {
+				return &ParamError{tn, n, errors.New("unknown parameter")}
+			}
+			if err := p.check(v); err != nil {
+				return &ParamError{tn, n, err}
+			}
+		}
+	}
+	return nil
+}
+
+// isBoolArg returns true if the argument following a bool `--name` is
+// its value, rather than a task.
+func (tr *Tasker) isBoolArg(arg string) bool {
+	if tr.Tasks[arg] != nil {
+		return false
+	}
+	_, err := strconv.ParseBool(arg)
+	return err == nil
+}
+
+// ParseTaskArgs parses command line arguments of task names, each
+// optionally followed by its Params. For example:
+//
+//	build deploy env=prod --dry-run
+//	deploy --env prod
+//
+// Bool Params given as `--name` without a value are set to true, unless
+// followed by a bool, as in `--dry-run false`. The
+// task names are returned in order, along with the parsed Params of each
+// task. An error is returned for unknown tasks or Params, and for values
+// that do not match the kind of their Param.
+func (tr *Tasker) ParseTaskArgs(args []string) (tns []string,
+	ps map[string]Params, err error) {
+
+	ps = make(map[string]Params)
+	var t *TaskerTask
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		var n, v string
+		var hasValue bool
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			n = strings.TrimPrefix(arg, "--")
+			if j := strings.Index(n, "="); j > -1 {
+				n, v, hasValue = n[:j], n[j+1:], true
+			}
+		case strings.Contains(arg, "="):
+			j := strings.Index(arg, "=")
+			n, v, hasValue = arg[:j], arg[j+1:], true
+		default:
+			t = tr.Tasks[arg]
+			if t == nil {
+				return nil, nil, &TaskNotFoundError{arg}
+			}
+			tns = append(tns, arg)
+			continue
+		}
+
+		if t == nil {
+			return nil, nil, &ParamError{"", n,
+				errors.New("must follow a task")}
+		}
+		p, ok := t.param(n)
+		if !ok {
+			return nil, nil, &ParamError{t.Name, n,
+				errors.New("unknown parameter")}
+		}
+
+		if !hasValue {
+			if p.Kind == BoolParamKind {
+				v = "true"
+				if i+1 < len(args) && tr.isBoolArg(args[i+1]) {
+					i++
+					v = args[i]
+				}
+			} else if i+1 < len(args) {
+				i++
+				v = args[i]
+			} else {
+				return nil, nil, &ParamError{t.Name, n,
+					errors.New("missing value")}
+			}
+		}
+
+		pv, err := p.Parse(v)
+		if err != nil {
+			return nil, nil, &ParamError{t.Name, n, errors.New(fmt.Sprintf(
+				"invalid %s \"%s\"", p.Kind, v))}
+		}
+		if ps[t.Name] == nil {
+			ps[t.Name] = make(Params)
+		}
+		ps[t.Name][n] = pv
+	}
+	return tns, ps, nil
+}
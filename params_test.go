@@ -0,0 +1,102 @@
+package muta
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newParamsTasker(got *Params) *Tasker {
+	ta := NewTasker()
+	ta.Task("deploy",
+		StringParam("env", "staging", "The environment"),
+		BoolParam("dry-run", false, "Do nothing"),
+		IntParam("retries", 3, "Number of retries"),
+		func(ps Params) error {
+			*got = ps
+			return nil
+		})
+	ta.Task("build", func() {})
+	return ta
+}
+
+func TestTaskerParseTaskArgs(t *testing.T) {
+	var got Params
+	ta := newParamsTasker(&got)
+
+	Convey("Should parse name=value params", t, func() {
+		tns, ps, err := ta.ParseTaskArgs([]string{
+			"build", "deploy", "env=prod", "retries=5"})
+		So(err, ShouldBeNil)
+		So(tns, ShouldResemble, []string{"build", "deploy"})
+		So(ps["deploy"], ShouldResemble, Params{"env": "prod", "retries": 5})
+	})
+
+	Convey("Should parse --name value params", t, func() {
+		_, ps, err := ta.ParseTaskArgs([]string{
+			"deploy", "--env", "prod", "--dry-run", "--retries=1"})
+		So(err, ShouldBeNil)
+		So(ps["deploy"], ShouldResemble, Params{
+			"env": "prod", "dry-run": true, "retries": 1})
+	})
+
+	Convey("Should parse a bool following a bool --name", t, func() {
+		tns, ps, err := ta.ParseTaskArgs([]string{
+			"deploy", "--dry-run", "false", "build"})
+		So(err, ShouldBeNil)
+		So(tns, ShouldResemble, []string{"deploy", "build"})
+		So(ps["deploy"], ShouldResemble, Params{"dry-run": false})
+
+		tns, ps, err = ta.ParseTaskArgs([]string{"deploy", "--dry-run", "build"})
+		So(err, ShouldBeNil)
+		So(tns, ShouldResemble, []string{"deploy", "build"})
+		So(ps["deploy"], ShouldResemble, Params{"dry-run": true})
+	})
+
+	Convey("Should error on", t, func() {
+		Convey("unknown tasks", func() {
+			_, _, err := ta.ParseTaskArgs([]string{"nope"})
+			So(err, ShouldNotBeNil)
+		})
+		Convey("unknown params", func() {
+			_, _, err := ta.ParseTaskArgs([]string{"deploy", "foo=bar"})
+			So(err, ShouldHaveSameTypeAs, &ParamError{})
+		})
+		Convey("invalid values", func() {
+			_, _, err := ta.ParseTaskArgs([]string{"deploy", "retries=x"})
+			So(err, ShouldHaveSameTypeAs, &ParamError{})
+		})
+		Convey("params without a task", func() {
+			_, _, err := ta.ParseTaskArgs([]string{"env=prod"})
+			So(err, ShouldNotBeNil)
+		})
+		Convey("missing values", func() {
+			_, _, err := ta.ParseTaskArgs([]string{"deploy", "--env"})
+			So(err, ShouldHaveSameTypeAs, &ParamError{})
+		})
+	})
+}
+
+func TestTaskerRunTasksWithParams(t *testing.T) {
+	Convey("Should give the task its params over the defaults", t, func() {
+		var got Params
+		ta := newParamsTasker(&got)
+		_, err := ta.RunTasksWithParams([]string{"deploy"},
+			map[string]Params{"deploy": {"env": "prod"}})
+		So(err, ShouldBeNil)
+		So(got.String("env"), ShouldEqual, "prod")
+		So(got.Bool("dry-run"), ShouldBeFalse)
+		So(got.Int("retries"), ShouldEqual, 3)
+	})
+
+	Convey("Should validate params before running any task", t, func() {
+		var got Params
+		ran := false
+		ta := newParamsTasker(&got)
+		ta.Task("first", func() { ran = true })
+		_, err := ta.RunTasksWithParams([]string{"first", "deploy"},
+			map[string]Params{"deploy": {"retries": "many"}})
+		So(err, ShouldHaveSameTypeAs, &ParamError{})
+		So(ran, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,210 @@
+package muta
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The pipeline file loaded by Te(), if it exists in the current
+// directory.
+const PipelineFile string = "muta.yaml"
+
+// Pipelines declare tasks without writing Go. For example:
+//
+//	tasks:
+//	  site:
+//	    description: Build the pages
+//	    deps: [clean]
+//	    src: ["pages/*.md"]
+//	    pipe:
+//	      - name: markdown
+//	        options: {smartypants: true}
+//	    dest: build
+//	  test:
+//	    command:
+//	      args: [go, test, ./...]
+//	      timeout: 5m
+//	  default:
+//	    deps: [site]
+//
+// Each Streamer of the pipe is built from a Registry, by name, so any
+// Streamer used must be registered by the muta.go or muta bin loading
+// the pipelines.
+type Pipelines struct {
+	Tasks map[string]Pipeline `yaml:"tasks"`
+}
+
+// A Pipeline is a single task of a pipeline file. If it has a Command
+// it is a Command task, if it has a Src, Pipe or Dest it is a Stream
+// task, otherwise it only runs its dependencies.
+type Pipeline struct {
+	Description  string   `yaml:"description"`
+	Hidden       bool     `yaml:"hidden"`
+	Dependencies []string `yaml:"deps"`
+
+	// The Src() globs of the Stream
+	Src []string `yaml:"src"`
+
+	// The registered Streamers, in the order they are piped
+	Pipe []PipelineStreamer `yaml:"pipe"`
+
+	// The Dest() directory of the Stream
+	Dest string `yaml:"dest"`
+
+	// The Command to run, instead of a Stream
+	Command *Command `yaml:"command"`
+}
+
+// A PipelineStreamer is a registered Streamer, and its options.
+type PipelineStreamer struct {
+	Name    string                 `yaml:"name"`
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// PipelineError is returned when a pipeline file, or one of its tasks,
+// is invalid.
+type PipelineError struct {
+	File string
+	Task string
+	Err  error
+}
+
+func (e *PipelineError) Error() string {
+	if e.Task == "" {
+		return fmt.Sprintf("Pipeline file '%s': %s", e.File, e.Err)
+	}
+	return fmt.Sprintf("Pipeline file '%s' task \"%s\": %s",
+		e.File, e.Task, e.Err)
+}
+
+// ParsePipelines parses the YAML content of a pipeline file.
+func ParsePipelines(b []byte) (Pipelines, error) {
+	var ps Pipelines
+	err := yaml.UnmarshalStrict(b, &ps)
+	return ps, err
+}
+
+// LoadPipelines reads the pipeline file at path, and adds its tasks to
+// the Tasker. Errors reading the file are returned as is, so that
+// os.IsNotExist() can be checked.
+func (tr *Tasker) LoadPipelines(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ps, err := ParsePipelines(b)
+	if err != nil {
+		return &PipelineError{File: path, Err: err}
+	}
+	if err := tr.AddPipelines(ps); err != nil {
+		if pe, ok := err.(*PipelineError); ok {
+			pe.File = path
+		}
+		return err
+	}
+	return nil
+}
+
+// AddPipelines adds the tasks of the Pipelines to the Tasker. The
+// Streamers of every task are looked up, and their options validated,
+// before any task is added.
+func (tr *Tasker) AddPipelines(ps Pipelines) error {
+	r := tr.registry()
+
+	// Sort the names, so that the same error is always returned
+	names := make([]string, 0, len(ps.Tasks))
+	for n := range ps.Tasks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	args := make(map[string][]interface{}, len(names))
+	for _, n := range names {
+		p := ps.Tasks[n]
+		a, err := p.taskArgs(r)
+		if err != nil {
+			return &PipelineError{Task: n, Err: err}
+		}
+		args[n] = a
+	}
+
+	for _, n := range names {
+		if err := tr.Task(n, args[n]...); err != nil {
+			return &PipelineError{Task: n, Err: err}
+		}
+	}
+	return nil
+}
+
+// taskArgs returns the arguments to give Tasker.Task() for this
+// Pipeline.
+func (p Pipeline) taskArgs(r *Registry) ([]interface{}, error) {
+	var args []interface{}
+	if p.Description != "" {
+		args = append(args, Desc(p.Description))
+	}
+	if p.Hidden {
+		args = append(args, Hidden())
+	}
+	for _, d := range p.Dependencies {
+		args = append(args, d)
+	}
+
+	hasStream := len(p.Src) > 0 || len(p.Pipe) > 0 || p.Dest != ""
+	if p.Command != nil {
+		if hasStream {
+			return nil, errors.New(
+				"a task cannot have both a command and a stream")
+		}
+		if len(p.Command.Args) == 0 {
+			return nil, errors.New("command has no args")
+		}
+		return append(args, *p.Command), nil
+	}
+	if !hasStream {
+		return args, nil
+	}
+
+	rss := make([]*RegisteredStreamer, len(p.Pipe))
+	pss := make([]Params, len(p.Pipe))
+	for i, ps := range p.Pipe {
+		if ps.Name == "" {
+			return nil, errors.New(fmt.Sprintf(
+				"pipe %d has no Streamer name", i))
+		}
+		rs, err := r.Streamer(ps.Name)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := rs.Params(ps.Options)
+		if err != nil {
+			return nil, err
+		}
+		rss[i], pss[i] = rs, opts
+	}
+
+	src := append([]string(nil), p.Src...)
+	dest := p.Dest
+	h := func() Stream {
+		s := Stream{}
+		if len(src) > 0 {
+			s = Src(append([]string(nil), src...)...)
+		}
+		for i, rs := range rss {
+			sr, err := rs.Factory(pss[i])
+			if err != nil {
+				sr = NewErrorStreamer(err.Error())
+			}
+			s = s.Pipe(sr)
+		}
+		if dest != "" {
+			s = s.Pipe(Dest(dest))
+		}
+		return s
+	}
+	return append(args, h), nil
+}
@@ -0,0 +1,215 @@
+package muta
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// A Registry with an "upper" Streamer, which uppercases the content of
+// files with the given extension.
+func testRegistry() *Registry {
+	r := NewRegistry()
+	r.RegisterStreamer("upper", "Uppercase files", func(ps Params) (
+		Streamer, error) {
+		ext := ps.String("ext")
+		return FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+			FileInfo, io.ReadCloser, error) {
+			if fi == nil || filepath.Ext(fi.Name()) != ext {
+				return fi, rc, nil
+			}
+			defer rc.Close()
+			b, err := ioutil.ReadAll(rc)
+			return fi, mutil.ByteCloser(bytes.ToUpper(b)), err
+		}), nil
+	}, StringParam("ext", ".md", "The extension of files to uppercase"))
+	return r
+}
+
+func TestParsePipelines(t *testing.T) {
+	Convey("Should parse tasks", t, func() {
+		ps, err := ParsePipelines([]byte(`
+tasks:
+  site:
+    description: Build the site
+    deps: [clean]
+    src: ["pages/*.md"]
+    pipe:
+      - name: upper
+        options: {ext: .txt}
+    dest: build
+  clean:
+    hidden: true
+`))
+		So(err, ShouldBeNil)
+		So(ps.Tasks["site"], ShouldResemble, Pipeline{
+			Description:  "Build the site",
+			Dependencies: []string{"clean"},
+			Src:          []string{"pages/*.md"},
+			Pipe: []PipelineStreamer{{
+				Name:    "upper",
+				Options: map[string]interface{}{"ext": ".txt"},
+			}},
+			Dest: "build",
+		})
+		So(ps.Tasks["clean"].Hidden, ShouldBeTrue)
+	})
+
+	Convey("Should parse command tasks", t, func() {
+		ps, err := ParsePipelines([]byte(`
+tasks:
+  test:
+    command:
+      args: [go, test]
+      env: [CGO_ENABLED=0]
+      timeout: 5m
+`))
+		So(err, ShouldBeNil)
+		So(ps.Tasks["test"].Command, ShouldResemble, &Command{
+			Args:    []string{"go", "test"},
+			Env:     []string{"CGO_ENABLED=0"},
+			Timeout: 5 * time.Minute,
+		})
+	})
+
+	Convey("Should return an error for unknown fields", t, func() {
+		_, err := ParsePipelines([]byte("tasks:\n  a:\n    foo: bar\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestTaskerAddPipelines(t *testing.T) {
+	Convey("Should add Stream tasks", t, func() {
+		dest := filepath.Join("_test", "tmp", "pipeline")
+		defer os.RemoveAll(dest)
+
+		ta := NewTasker()
+		ta.Registry = testRegistry()
+		ta.Task("go", func() {})
+		err := ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"upper": {
+				Description:  "Uppercase markdown",
+				Dependencies: []string{"go"},
+				Src:          []string{filepath.Join("_test", "fixtures", "*.md")},
+				Pipe:         []PipelineStreamer{{Name: "upper"}},
+				Dest:         dest,
+			},
+			"default": {Dependencies: []string{"upper"}},
+		}})
+		So(err, ShouldBeNil)
+		So(ta.Tasks["upper"].Description, ShouldEqual, "Uppercase markdown")
+		So(ta.Tasks["upper"].Dependencies, ShouldResemble, []string{"go"})
+
+		// Run twice, to check a new Stream is built each time
+		for i := 0; i < 2; i++ {
+			rs, err := ta.RunTasks("default")
+			So(err, ShouldBeNil)
+			So(len(rs), ShouldEqual, 3)
+		}
+
+		in, _ := ioutil.ReadFile(filepath.Join("_test", "fixtures", "hello.md"))
+		out, err := ioutil.ReadFile(filepath.Join(dest, "hello.md"))
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, strings.ToUpper(string(in)))
+	})
+
+	Convey("Should validate every task before adding any", t, func() {
+		ta := NewTasker()
+		ta.Registry = testRegistry()
+		err := ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"a": {Pipe: []PipelineStreamer{{Name: "upper"}}},
+			"b": {Pipe: []PipelineStreamer{{Name: "foo"}}},
+		}})
+		So(err, ShouldHaveSameTypeAs, &PipelineError{})
+		So(err.(*PipelineError).Task, ShouldEqual, "b")
+		So(ExitCode(err), ShouldEqual, ExitValidation)
+		So(ta.Tasks["a"], ShouldBeNil)
+
+		err = ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"a": {Pipe: []PipelineStreamer{{
+				Name:    "upper",
+				Options: map[string]interface{}{"ext": 1},
+			}}},
+		}})
+		So(err, ShouldHaveSameTypeAs, &PipelineError{})
+	})
+
+	Convey("Should add Command tasks", t, func() {
+		ta := NewTasker()
+		err := ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"a": {Command: &Command{Args: []string{"true"}}},
+		}})
+		So(err, ShouldBeNil)
+		So(ta.Tasks["a"].Command, ShouldResemble, &Command{Args: []string{"true"}})
+
+		err = ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"b": {Command: &Command{Args: []string{"true"}}, Dest: "build"},
+		}})
+		So(err, ShouldHaveSameTypeAs, &PipelineError{})
+	})
+
+	Convey("Should fail a task whose first Streamer can't be built", t,
+		func() {
+			ta := NewTasker()
+			ta.Registry = NewRegistry()
+			ta.Registry.RegisterStreamer("fail", "Always fails",
+				func(Params) (Streamer, error) {
+					return nil, errors.New("invalid fail")
+				})
+			err := ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+				"a": {Pipe: []PipelineStreamer{{Name: "fail"}}},
+			}})
+			So(err, ShouldBeNil)
+
+			var b bytes.Buffer
+			ta.WriteGraph(&b)
+			So(b.String(), ShouldContainSubstring, "a")
+
+			_, err = ta.RunTasks("a")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "invalid fail")
+		})
+
+	Convey("Should not replace existing tasks", t, func() {
+		ta := NewTasker()
+		ta.Task("a", func() {})
+		err := ta.AddPipelines(Pipelines{Tasks: map[string]Pipeline{
+			"a": {},
+		}})
+		So(err, ShouldHaveSameTypeAs, &PipelineError{})
+	})
+}
+
+func TestTaskerLoadPipelines(t *testing.T) {
+	Convey("Should load a pipeline file", t, func() {
+		dir, err := ioutil.TempDir("", "muta-pipeline")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		p := filepath.Join(dir, PipelineFile)
+
+		ta := NewTasker()
+		ta.Registry = testRegistry()
+		err = ta.LoadPipelines(p)
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		ioutil.WriteFile(p, []byte("tasks:\n  a:\n    pipe: [{name: foo}]\n"),
+			0644)
+		err = ta.LoadPipelines(p)
+		So(err, ShouldHaveSameTypeAs, &PipelineError{})
+		So(err.Error(), ShouldContainSubstring, p)
+
+		ioutil.WriteFile(p, []byte("tasks:\n  a:\n    pipe: [{name: upper}]\n"),
+			0644)
+		So(ta.LoadPipelines(p), ShouldBeNil)
+		So(ta.Tasks["a"].StreamHandler, ShouldNotBeNil)
+	})
+}
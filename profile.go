@@ -0,0 +1,242 @@
+package muta
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leeola/muta/logging"
+)
+
+// NewProfiler returns an empty Profiler, ready to be given to
+// Stream.Profile()
+func NewProfiler() *Profiler {
+	return &Profiler{tasks: make(map[string]time.Duration)}
+}
+
+// A Profiler times every call to the Streamers of a profiled Stream,
+// and counts the bytes flowing through them. See Stream.Profile() for
+// details.
+type Profiler struct {
+	mu        sync.Mutex
+	streamers []*profileStat
+	tasks     map[string]time.Duration
+	taskOrder []string
+}
+
+// ProfileStat is the aggregated profile of a single Streamer, or of an
+// entire task if Streamer is empty.
+type ProfileStat struct {
+	Task     string `json:"task"`
+	Streamer string `json:"streamer,omitempty"`
+
+	// The number of times Next() was called, and the number of files
+	// that were returned.
+	Calls int64 `json:"calls"`
+	Files int64 `json:"files"`
+
+	// The time spent in Next() calls. For a task, this is the total time
+	// spent running the task.
+	Duration time.Duration `json:"duration"`
+
+	// The bytes read from incoming files, and read from returned files.
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+type profileStat struct {
+	mu sync.Mutex
+	ProfileStat
+}
+
+// Stats returns the ProfileStat of every profiled Streamer, in the
+// order they were profiled.
+func (p *Profiler) Stats() []ProfileStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ProfileStat, len(p.streamers))
+	for i, ps := range p.streamers {
+		ps.mu.Lock()
+		stats[i] = ProfileStat{
+			Task:     ps.Task,
+			Streamer: ps.Streamer,
+			Calls:    ps.Calls,
+			Files:    ps.Files,
+			Duration: ps.Duration,
+			BytesIn:  atomic.LoadInt64(&ps.BytesIn),
+			BytesOut: atomic.LoadInt64(&ps.BytesOut),
+		}
+		ps.mu.Unlock()
+	}
+	return stats
+}
+
+// TaskStats returns the ProfileStats of every Streamer aggregated by
+// task, in the order the tasks completed. The Duration of each is the
+// total time spent running the task, if it was recorded with
+// AddTask(), otherwise the sum of its Streamers.
+func (p *Profiler) TaskStats() []ProfileStat {
+	stats := p.Stats()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	order := append([]string{}, p.taskOrder...)
+	byTask := make(map[string]*ProfileStat)
+	for _, t := range order {
+		byTask[t] = &ProfileStat{Task: t}
+	}
+	for _, s := range stats {
+		ts := byTask[s.Task]
+		if ts == nil {
+			ts = &ProfileStat{Task: s.Task}
+			byTask[s.Task] = ts
+			order = append(order, s.Task)
+		}
+		ts.Calls += s.Calls
+		ts.Files += s.Files
+		ts.Duration += s.Duration
+		ts.BytesIn += s.BytesIn
+		ts.BytesOut += s.BytesOut
+	}
+
+	tstats := make([]ProfileStat, len(order))
+	for i, t := range order {
+		tstats[i] = *byTask[t]
+		if d, ok := p.tasks[t]; ok {
+			tstats[i].Duration = d
+		}
+	}
+	return tstats
+}
+
+// AddTask records the total time spent running the given task.
+func (p *Profiler) AddTask(task string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.tasks[task]; !ok {
+		p.taskOrder = append(p.taskOrder, task)
+	}
+	p.tasks[task] += d
+}
+
+// WriteSummary writes a table of the ProfileStats of every Streamer,
+// followed by a total for each task.
+func (p *Profiler) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tSTREAMER\tCALLS\tFILES\tTIME\tBYTES IN\tBYTES OUT")
+	for _, s := range p.Stats() {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%d\t%d\n", s.Task, s.Streamer,
+			s.Calls, s.Files, s.Duration, s.BytesIn, s.BytesOut)
+	}
+	for _, s := range p.TaskStats() {
+		fmt.Fprintf(tw, "%s\t(total)\t%d\t%d\t%s\t%d\t%d\n", s.Task,
+			s.Calls, s.Files, s.Duration, s.BytesIn, s.BytesOut)
+	}
+	return tw.Flush()
+}
+
+// Profile returns a copy of this Stream which times every call to its
+// Streamers, and counts the files and bytes going through them. The
+// results are aggregated in the given Profiler, under the given task.
+//
+// Note that the time spent in Next() may not include all of the work a
+// Streamer does, as the content of a file is often read lazily by the
+// following Streamers.
+func (s Stream) Profile(p *Profiler, task string) Stream {
+	return s.Wrap(func(sr Streamer) Streamer {
+		ps := &profileStat{}
+		ps.Task = task
+		ps.Streamer = Describe(sr).Name
+
+		p.mu.Lock()
+		p.streamers = append(p.streamers, ps)
+		p.mu.Unlock()
+
+		return &profileStreamer{Streamer: sr, stat: ps}
+	})
+}
+
+type profileStreamer struct {
+	Streamer
+	stat *profileStat
+}
+
+func (s *profileStreamer) Describe() Description {
+	return Describe(s.Streamer)
+}
+
+// Close closes the wrapped Streamer, if it implements io.Closer.
+func (s *profileStreamer) Close() error {
+	if c, ok := s.Streamer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SetLogger gives the Logger to the wrapped Streamer, if it implements
+// LoggerSetter.
+func (s *profileStreamer) SetLogger(l *logging.Logger) {
+	if ls, ok := s.Streamer.(LoggerSetter); ok {
+		ls.SetLogger(l)
+	}
+}
+
+func (s *profileStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	return emitOne(s, fi, rc)
+}
+
+func (s *profileStreamer) Emit(fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) error {
+
+	rc, err := bufferInput(s.Streamer, rc)
+	if err != nil {
+		return err
+	}
+	_, rc = newCounter(rc, &s.stat.BytesIn)
+	count := func(orc io.ReadCloser) io.ReadCloser {
+		_, orc = newCounter(orc, &s.stat.BytesOut)
+		return orc
+	}
+
+	var files int64
+	var downstream time.Duration
+	start := time.Now()
+	defer func() {
+		s.stat.mu.Lock()
+		s.stat.Calls++
+		s.stat.Files += files
+		s.stat.Duration += time.Since(start) - downstream
+		s.stat.mu.Unlock()
+	}()
+
+	if e, ok := s.Streamer.(Emitter); ok {
+		// Time spent in emit is spent in the following Streamers, so it is
+		// removed from the duration of this one.
+		return e.Emit(fi, rc, func(efi FileInfo, erc io.ReadCloser) error {
+			if efi == nil {
+				return nil
+			}
+			files++
+			emitStart := time.Now()
+			err := emit(efi, count(erc))
+			downstream += time.Since(emitStart)
+			return err
+		})
+	}
+
+	ofi, orc, err := s.Streamer.Next(fi, rc)
+	if err != nil || ofi == nil {
+		return err
+	}
+	files++
+	emitStart := time.Now()
+	err = emit(ofi, count(orc))
+	downstream += time.Since(emitStart)
+	return err
+}
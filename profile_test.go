@@ -0,0 +1,93 @@
+package muta
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/leeola/muta/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamProfile(t *testing.T) {
+	Convey("Should aggregate calls, files and bytes per Streamer", t, func() {
+		p := NewProfiler()
+		s := Stream{
+			&MockStreamer{Files: []string{"foo", "bar"}},
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				if fi != nil {
+					ioutil.ReadAll(rc)
+				}
+				return fi, rc, nil
+			}),
+		}
+		err := s.Profile(p, "a").Stream()
+		So(err, ShouldBeNil)
+
+		stats := p.Stats()
+		So(len(stats), ShouldEqual, 2)
+		So(stats[0].Task, ShouldEqual, "a")
+		So(stats[0].Streamer, ShouldEqual, "*muta.MockStreamer")
+		So(stats[0].Calls, ShouldEqual, 3)
+		So(stats[0].Files, ShouldEqual, 2)
+		So(stats[0].BytesOut, ShouldEqual,
+			len("foo content")+len("bar content"))
+		So(stats[1].Calls, ShouldEqual, 3)
+		So(stats[1].BytesIn, ShouldEqual,
+			len("foo content")+len("bar content"))
+	})
+
+	Convey("Should aggregate per task", t, func() {
+		p := NewProfiler()
+		Stream{&MockStreamer{Files: []string{"foo"}}}.Profile(p, "a").Stream()
+		Stream{&MockStreamer{Files: []string{"bar"}}}.Profile(p, "b").Stream()
+		p.AddTask("b", time.Second)
+
+		tstats := p.TaskStats()
+		So(len(tstats), ShouldEqual, 2)
+		So(tstats[0].Task, ShouldEqual, "b")
+		So(tstats[0].Duration, ShouldEqual, time.Second)
+		So(tstats[1].Task, ShouldEqual, "a")
+		So(tstats[1].Files, ShouldEqual, 1)
+	})
+
+	Convey("Should write a summary table", t, func() {
+		p := NewProfiler()
+		Stream{&MockStreamer{Files: []string{"foo"}}}.Profile(p, "a").Stream()
+		var b bytes.Buffer
+		So(p.WriteSummary(&b), ShouldBeNil)
+		So(b.String(), ShouldContainSubstring, "STREAMER")
+		So(b.String(), ShouldContainSubstring, "*muta.MockStreamer")
+		So(b.String(), ShouldContainSubstring, "(total)")
+	})
+
+	Convey("Should forward Close and SetLogger", t, func() {
+		c, ls := &closeStreamer{}, &logStreamer{}
+		s := Stream{c, ls}.Profile(NewProfiler(), "")
+		So(s.Close(), ShouldBeNil)
+		So(c.closed, ShouldBeTrue)
+		l := logging.NewLogger(ioutil.Discard)
+		s[1].(LoggerSetter).SetLogger(l)
+		So(ls.Logger(), ShouldEqual, l)
+	})
+}
+
+func TestTaskerProfiler(t *testing.T) {
+	Convey("Should profile tasks", t, func() {
+		ta := NewTasker()
+		ta.Profiler = NewProfiler()
+		ta.Task("a", func() Stream {
+			return Stream{&MockStreamer{Files: []string{"foo"}}}
+		})
+		ta.Task("b", "a", func() {})
+		So(ta.RunTask("b"), ShouldBeNil)
+		tstats := ta.Profiler.TaskStats()
+		So(len(tstats), ShouldEqual, 2)
+		So(tstats[0].Task, ShouldEqual, "a")
+		So(tstats[0].Files, ShouldEqual, 1)
+		So(tstats[1].Task, ShouldEqual, "b")
+	})
+}
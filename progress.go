@@ -0,0 +1,217 @@
+package muta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leeola/muta/logging"
+)
+
+// How often the lines of running tasks are redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// Progress draws a line for every running task on a terminal, showing
+// the number of files read and written so far and the time elapsed.
+// Once a task finishes, its line collapses to a one line summary. For
+// example:
+//
+//	site ok (12 read, 12 written) in 1.2s
+//	assets running (3 read, 1 written) 0.4s
+//
+// The running lines are redrawn in place with ANSI escape codes, so
+// anything else written to the terminal must go through Writer(), or
+// Stdout().
+type Progress struct {
+	// Color the status of every summary line
+	Color bool
+
+	w     io.Writer
+	mu    sync.Mutex
+	tasks []*taskProgress
+	drawn int
+	done  chan struct{}
+}
+
+type taskProgress struct {
+	name  string
+	start time.Time
+
+	// The number of files read by Srcs and written by Dests, updated
+	// atomically.
+	read    int64
+	written int64
+}
+
+func (tp *taskProgress) counts() string {
+	return fmt.Sprintf("(%d read, %d written)",
+		atomic.LoadInt64(&tp.read), atomic.LoadInt64(&tp.written))
+}
+
+// Return a Progress drawing to w, which should be a terminal. See
+// logging.IsTerminal.
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{w: w}
+}
+
+// Writer returns an io.Writer which writes to w without mangling the
+// running task lines, by clearing them before every write and drawing
+// them again after. The Logger of the Tasker should write through it.
+func (p *Progress) Writer(w io.Writer) io.Writer {
+	return progressWriter{p, w}
+}
+
+type progressWriter struct {
+	p *Progress
+	w io.Writer
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	pw.p.clear()
+	n, err := pw.w.Write(b)
+	pw.p.draw()
+	return n, err
+}
+
+// Stdout redirects os.Stdout through Writer(), so that what tasks print
+// to a terminal shared with the running lines is not drawn over, until
+// the returned func is called. Output is passed on a line at a time.
+func (p *Progress) Stdout() (restore func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan struct{})
+	go func() {
+		copyLines(p.Writer(stdout), r)
+		close(done)
+	}()
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+		r.Close()
+	}, nil
+}
+
+// copyLines copies r to w a line at a time, so that the running lines
+// are only redrawn between whole lines.
+func copyLines(w io.Writer, r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		l, err := br.ReadBytes('\n')
+		if len(l) > 0 {
+			w.Write(l)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// begin adds a running line for the task.
+func (p *Progress) begin(tn string) *taskProgress {
+	tp := &taskProgress{name: tn, start: time.Now()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.tasks = append(p.tasks, tp)
+	p.draw()
+
+	if p.done == nil {
+		p.done = make(chan struct{})
+		go p.tick(p.done)
+	}
+	return tp
+}
+
+// end replaces the running line of the task with a summary of the
+// result.
+func (p *Progress) end(tp *taskProgress, r *TaskResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	for i, t := range p.tasks {
+		if t == tp {
+			p.tasks = append(p.tasks[:i], p.tasks[i+1:]...)
+			break
+		}
+	}
+
+	status := string(r.Status)
+	if p.Color {
+		c := logging.ColorGreen
+		if r.Status != TaskOK {
+			c = logging.ColorRed
+		}
+		status = c + status + logging.ColorReset
+	}
+	fmt.Fprintf(p.w, "%s %s %s in %s\n", tp.name, status, tp.counts(),
+		roundDuration(r.Duration))
+	p.draw()
+
+	if len(p.tasks) == 0 && p.done != nil {
+		close(p.done)
+		p.done = nil
+	}
+}
+
+// tick redraws the running lines until done is closed, so that the
+// counts and elapsed time stay current.
+func (p *Progress) tick(done chan struct{}) {
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			p.mu.Lock()
+			p.clear()
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// clear erases the drawn running lines, leaving the cursor where the
+// first of them was. The caller must hold the lock.
+func (p *Progress) clear() {
+	if p.drawn > 0 {
+		// Move to the start of the first line, and erase to the end
+		fmt.Fprintf(p.w, "\x1b[%dF\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// draw writes a line for every running task. The caller must hold the
+// lock.
+func (p *Progress) draw() {
+	var b bytes.Buffer
+	for _, tp := range p.tasks {
+		fmt.Fprintf(&b, "%s running %s %s\n", tp.name, tp.counts(),
+			roundDuration(time.Since(tp.start)))
+	}
+	p.w.Write(b.Bytes())
+	p.drawn = len(p.tasks)
+}
+
+// Round a duration for display, to tenths of a second once past one
+// second.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
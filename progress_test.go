@@ -0,0 +1,157 @@
+package muta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leeola/muta/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// A writer which can be read while the Progress is drawing to it.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestProgress(t *testing.T) {
+	Convey("Should draw and collapse the lines of tasks", t, func() {
+		var b syncBuffer
+		p := NewProgress(&b)
+		tp := p.begin("a")
+		So(b.String(), ShouldStartWith, "a running (0 read, 0 written) ")
+
+		tp.read, tp.written = 2, 1
+		p.end(tp, &TaskResult{Status: TaskOK})
+		So(b.String(), ShouldEndWith,
+			"\x1b[1F\x1b[Ja ok (2 read, 1 written) in 0s\n")
+		So(p.done, ShouldBeNil)
+	})
+
+	Convey("Should keep the lines of tasks still running", t, func() {
+		var b syncBuffer
+		p := NewProgress(&b)
+		a := p.begin("a")
+		p.begin("b")
+		p.end(a, &TaskResult{Status: TaskFailed})
+		So(b.String(), ShouldContainSubstring,
+			"\x1b[2F\x1b[Ja failed (0 read, 0 written) in 0s\nb running")
+		So(p.drawn, ShouldEqual, 1)
+	})
+
+	Convey("Should color the status", t, func() {
+		var b syncBuffer
+		p := NewProgress(&b)
+		p.Color = true
+		p.end(p.begin("a"), &TaskResult{Status: TaskOK})
+		So(b.String(), ShouldContainSubstring,
+			"a "+logging.ColorGreen+"ok"+logging.ColorReset)
+	})
+
+	Convey("Should write around the running lines", t, func() {
+		var b syncBuffer
+		p := NewProgress(&b)
+		tp := p.begin("a")
+		p.Writer(&b).Write([]byte("log\n"))
+		So(b.String(), ShouldContainSubstring, "\x1b[1F\x1b[Jlog\na running")
+		p.end(tp, &TaskResult{Status: TaskOK})
+	})
+	Convey("Should write around the running lines what tasks print", t,
+		func() {
+			f, err := ioutil.TempFile("", "muta-stdout")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			// Goconvey reports to os.Stdout, so nothing is asserted until
+			// it is restored
+			stdout := os.Stdout
+			os.Stdout = f
+			p := NewProgress(f)
+			tp := p.begin("a")
+			restore, err := p.Stdout()
+			if err == nil {
+				fmt.Fprint(os.Stdout, "printed")
+				fmt.Fprintln(os.Stdout, " line")
+				restore()
+			}
+			p.end(tp, &TaskResult{Status: TaskOK})
+			restored := os.Stdout == f
+			os.Stdout = stdout
+
+			So(err, ShouldBeNil)
+			So(restored, ShouldBeTrue)
+			b, err := ioutil.ReadFile(f.Name())
+			So(err, ShouldBeNil)
+			So(string(b), ShouldContainSubstring,
+				"\x1b[1F\x1b[Jprinted line\na running")
+		})
+}
+
+// Records every write.
+type writes []string
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+func TestCopyLines(t *testing.T) {
+	Convey("Should copy a line at a time", t, func() {
+		var w writes
+		copyLines(&w, strings.NewReader("a\nbc\nd"))
+		So([]string(w), ShouldResemble, []string{"a\n", "bc\n", "d"})
+	})
+}
+
+func TestTaskerProgress(t *testing.T) {
+	Convey("Should count the files read and written", t, func() {
+		var b syncBuffer
+		dest := filepath.Join("_test", "tmp", "progress")
+		defer os.RemoveAll(dest)
+
+		ta := NewTasker()
+		ta.Progress = NewProgress(&b)
+		ta.Task("a", func() Stream {
+			return Src(filepath.Join("_test", "fixtures", "*.md")).
+				Pipe(Dest(dest))
+		})
+		ta.Task("b", "a", func() error { return errors.New("foo") })
+		_, err := ta.RunTasks("b")
+		So(err, ShouldNotBeNil)
+
+		lines := strings.Split(b.String(), "\n")
+		var summaries []string
+		for _, l := range lines {
+			if i := strings.LastIndex(l, "\x1b[J"); i > -1 {
+				l = l[i+3:]
+			}
+			if strings.Contains(l, " in ") {
+				summaries = append(summaries, l[:strings.Index(l, " in ")])
+			}
+		}
+		So(summaries, ShouldResemble, []string{
+			"a ok (2 read, 2 written)",
+			"b failed (0 read, 0 written)",
+		})
+	})
+}
@@ -0,0 +1,310 @@
+package muta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+	"unicode"
+)
+
+// StreamerFactory builds a new Streamer from its options. The Params
+// hold a value for every option the Streamer was registered with.
+type StreamerFactory func(Params) (Streamer, error)
+
+// A RegisteredStreamer is a StreamerFactory registered under a name,
+// along with its description and the schema of its options.
+type RegisteredStreamer struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Options     []Param         `json:"options"`
+	Factory     StreamerFactory `json:"-"`
+}
+
+// option returns the named option of the Streamer, if any.
+func (rs *RegisteredStreamer) option(n string) (Param, bool) {
+	for _, p := range rs.Options {
+		if p.Name == n {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Params validates the given options against the options schema,
+// returning them merged over the option defaults.
+func (rs *RegisteredStreamer) Params(opts map[string]interface{}) (
+	Params, error) {
+
+	ps := make(Params, len(rs.Options))
+	for _, p := range rs.Options {
+		ps[p.Name] = p.Default
+	}
+	for n, v := range opts {
+		p, ok := rs.option(n)
+		if !ok {
+			return nil, &ParamError{rs.Name, n,
+				errors.New("unknown option")}
+		}
+		if err := p.check(v); err != nil {
+			return nil, &ParamError{rs.Name, n, err}
+		}
+		ps[n] = v
+	}
+	return ps, nil
+}
+
+// New validates the given options and builds a new Streamer with them.
+func (rs *RegisteredStreamer) New(opts map[string]interface{}) (
+	Streamer, error) {
+
+	ps, err := rs.Params(opts)
+	if err != nil {
+		return nil, err
+	}
+	return rs.Factory(ps)
+}
+
+// A Registry holds Streamers by name, so that they can be discovered,
+// and built from pipeline files. Streamers are usually registered to
+// the DefaultRegistry, within the init() of their package.
+type Registry struct {
+	streamers map[string]*RegisteredStreamer
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		streamers: make(map[string]*RegisteredStreamer),
+	}
+}
+
+var DefaultRegistry *Registry = NewRegistry()
+
+// Register registers the constructor with the DefaultRegistry. See
+// Registry.Register() for details.
+func Register(name, description string, defaults interface{},
+	constructor interface{}) error {
+	return DefaultRegistry.Register(name, description, defaults, constructor)
+}
+
+// RegisterStreamer registers the factory with the DefaultRegistry.
+func RegisterStreamer(name, description string, f StreamerFactory,
+	opts ...Param) error {
+	return DefaultRegistry.RegisterStreamer(name, description, f, opts...)
+}
+
+// LookupStreamer returns the Streamer registered with the
+// DefaultRegistry under the given name.
+func LookupStreamer(name string) (*RegisteredStreamer, error) {
+	return DefaultRegistry.Streamer(name)
+}
+
+// Streamers returns every Streamer registered with the DefaultRegistry,
+// sorted by name.
+func Streamers() []*RegisteredStreamer {
+	return DefaultRegistry.Streamers()
+}
+
+// Register registers a Streamer constructor which takes a typed options
+// struct. The constructor must be a func taking the same type as
+// defaults, and returning a Streamer, optionally with an error. For
+// example:
+//
+//	type MarkdownOpts struct {
+//		Smartypants bool   `help:"Use smart punctuation"`
+//		Layout      string `muta:"layout_file"`
+//	}
+//
+//	muta.Register("markdown", "Render Markdown files to HTML",
+//		MarkdownOpts{Smartypants: true},
+//		func(o MarkdownOpts) muta.Streamer { ... })
+//
+// Each exported string, bool and int field of the struct is an option,
+// defaulting to its value in defaults. Options are named by the `muta`
+// tag of the field, or the field name in snake_case if there is none,
+// and described by the `help` tag. Fields tagged `muta:"-"` are
+// ignored.
+func (r *Registry) Register(name, description string, defaults interface{},
+	constructor interface{}) error {
+
+	dv := reflect.ValueOf(defaults)
+	if dv.Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf(
+			"Streamer \"%s\" defaults must be a struct, got %T",
+			name, defaults))
+	}
+	dt := dv.Type()
+
+	cv := reflect.ValueOf(constructor)
+	ct := cv.Type()
+	streamerType := reflect.TypeOf((*Streamer)(nil)).Elem()
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if ct.Kind() != reflect.Func || ct.NumIn() != 1 || ct.In(0) != dt ||
+		ct.NumOut() < 1 || ct.NumOut() > 2 ||
+		!ct.Out(0).Implements(streamerType) ||
+		(ct.NumOut() == 2 && ct.Out(1) != errorType) {
+		return errors.New(fmt.Sprintf(
+			"Streamer \"%s\" constructor must be a func(%s) muta.Streamer, "+
+				"got %s", name, dt, ct))
+	}
+
+	var opts []Param
+	var fields []int
+	for i := 0; i < dt.NumField(); i++ {
+		f := dt.Field(i)
+		on := f.Tag.Get("muta")
+		if f.PkgPath != "" || on == "-" {
+			continue
+		}
+		if on == "" {
+			on = snakeCase(f.Name)
+		}
+
+		var k ParamKind
+		switch f.Type.Kind() {
+		case reflect.String:
+			k = StringParamKind
+		case reflect.Bool:
+			k = BoolParamKind
+		case reflect.Int:
+			k = IntParamKind
+		default:
+			return errors.New(fmt.Sprintf(
+				"Streamer \"%s\" option %s has unsupported type %s",
+				name, f.Name, f.Type))
+		}
+
+		// Convert named types, such as `type Mode string`, so that the
+		// default is of the kind of the option.
+		var def interface{}
+		switch k {
+		case StringParamKind:
+			def = dv.Field(i).String()
+		case BoolParamKind:
+			def = dv.Field(i).Bool()
+		case IntParamKind:
+			def = int(dv.Field(i).Int())
+		}
+
+		opts = append(opts, Param{
+			Name:    on,
+			Kind:    k,
+			Default: def,
+			Help:    f.Tag.Get("help"),
+		})
+		fields = append(fields, i)
+	}
+
+	f := func(ps Params) (Streamer, error) {
+		v := reflect.New(dt).Elem()
+		v.Set(dv)
+		for i, o := range opts {
+			fv := v.Field(fields[i])
+			fv.Set(reflect.ValueOf(ps[o.Name]).Convert(fv.Type()))
+		}
+
+		out := cv.Call([]reflect.Value{v})
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		sr, _ := out[0].Interface().(Streamer)
+		return sr, nil
+	}
+
+	return r.RegisterStreamer(name, description, f, opts...)
+}
+
+// RegisterStreamer registers the factory under the given name, with
+// the given options. An error is returned if the name is already
+// registered.
+func (r *Registry) RegisterStreamer(name, description string,
+	f StreamerFactory, opts ...Param) error {
+
+	if r.streamers[name] != nil {
+		return errors.New(fmt.Sprintf(
+			"Streamer \"%s\" is already registered", name))
+	}
+	if opts == nil {
+		opts = []Param{}
+	}
+	r.streamers[name] = &RegisteredStreamer{
+		Name:        name,
+		Description: description,
+		Options:     opts,
+		Factory:     f,
+	}
+	return nil
+}
+
+// Streamer returns the Streamer registered under the given name.
+func (r *Registry) Streamer(name string) (*RegisteredStreamer, error) {
+	rs := r.streamers[name]
+	if rs == nil {
+		return nil, errors.New(fmt.Sprintf(
+			"Streamer \"%s\" is not registered", name))
+	}
+	return rs, nil
+}
+
+// Streamers returns every registered Streamer, sorted by name.
+func (r *Registry) Streamers() []*RegisteredStreamer {
+	rss := make([]*RegisteredStreamer, 0, len(r.streamers))
+	for _, rs := range r.streamers {
+		rss = append(rss, rs)
+	}
+	sort.Sort(registeredStreamers(rss))
+	return rss
+}
+
+// WriteList writes the registered Streamers as an aligned table of
+// names and descriptions, each followed by its options.
+func (r *Registry) WriteList(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, rs := range r.Streamers() {
+		fmt.Fprintf(tw, "%s\t%s\n", rs.Name, rs.Description)
+		for _, p := range rs.Options {
+			fmt.Fprintln(tw, paramLine(p))
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteListJSON writes the registered Streamers as a JSON array.
+func (r *Registry) WriteListJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Streamers())
+}
+
+// registry returns the Registry of the Tasker, or the DefaultRegistry
+// if it has none.
+func (tr *Tasker) registry() *Registry {
+	if tr.Registry == nil {
+		return DefaultRegistry
+	}
+	return tr.Registry
+}
+
+// Convert a Go field name to snake_case, such as `SkipDrafts` to
+// `skip_drafts` and `HTMLFile` to `html_file`.
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b []rune
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(rs[i-1]) ||
+			(i+1 < len(rs) && unicode.IsLower(rs[i+1]))) {
+			b = append(b, '_')
+		}
+		b = append(b, unicode.ToLower(r))
+	}
+	return string(b)
+}
+
+type registeredStreamers []*RegisteredStreamer
+
+func (rss registeredStreamers) Len() int           { return len(rss) }
+func (rss registeredStreamers) Less(i, j int) bool { return rss[i].Name < rss[j].Name }
+func (rss registeredStreamers) Swap(i, j int)      { rss[i], rss[j] = rss[j], rss[i] }
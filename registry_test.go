@@ -0,0 +1,157 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testOpts struct {
+	Ext        string `help:"The extension"`
+	SkipDrafts bool
+	MaxFiles   int    `muta:"max"`
+	Ignored    string `muta:"-"`
+	unexported string
+}
+
+type testStreamer struct {
+	opts testOpts
+}
+
+func (s *testStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	return fi, rc, nil
+}
+
+func TestRegistryRegisterStreamer(t *testing.T) {
+	Convey("Should register Streamers by name", t, func() {
+		var given Params
+		r := NewRegistry()
+		err := r.RegisterStreamer("mock", "A mock", func(ps Params) (
+			Streamer, error) {
+			given = ps
+			return &MockStreamer{}, nil
+		}, StringParam("foo", "bar", ""), IntParam("n", 1, ""))
+		So(err, ShouldBeNil)
+
+		rs, err := r.Streamer("mock")
+		So(err, ShouldBeNil)
+		So(rs.Description, ShouldEqual, "A mock")
+		sr, err := rs.New(map[string]interface{}{"n": 2})
+		So(err, ShouldBeNil)
+		So(sr, ShouldHaveSameTypeAs, &MockStreamer{})
+		So(given, ShouldResemble, Params{"foo": "bar", "n": 2})
+
+		err = r.RegisterStreamer("mock", "", nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should return an error for unknown Streamers", t, func() {
+		_, err := NewRegistry().Streamer("foo")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should validate options", t, func() {
+		rs := &RegisteredStreamer{
+			Name:    "mock",
+			Options: []Param{BoolParam("foo", false, "")},
+		}
+		_, err := rs.Params(map[string]interface{}{"bar": true})
+		So(err, ShouldResemble, &ParamError{"mock", "bar",
+			errors.New("unknown option")})
+
+		_, err = rs.Params(map[string]interface{}{"foo": "true"})
+		So(err, ShouldHaveSameTypeAs, &ParamError{})
+	})
+}
+
+func TestRegistryRegister(t *testing.T) {
+	Convey("Should derive the options from the struct", t, func() {
+		r := NewRegistry()
+		err := r.Register("test", "A test", testOpts{Ext: ".md", MaxFiles: 3},
+			func(o testOpts) Streamer { return &testStreamer{o} })
+		So(err, ShouldBeNil)
+
+		rs, _ := r.Streamer("test")
+		So(rs.Options, ShouldResemble, []Param{
+			StringParam("ext", ".md", "The extension"),
+			BoolParam("skip_drafts", false, ""),
+			IntParam("max", 3, ""),
+		})
+
+		sr, err := rs.New(map[string]interface{}{"skip_drafts": true})
+		So(err, ShouldBeNil)
+		So(sr.(*testStreamer).opts, ShouldResemble, testOpts{
+			Ext:        ".md",
+			SkipDrafts: true,
+			MaxFiles:   3,
+		})
+	})
+
+	Convey("Should return constructor errors", t, func() {
+		r := NewRegistry()
+		err := r.Register("test", "", struct{}{},
+			func(struct{}) (Streamer, error) {
+				return nil, errors.New("foo")
+			})
+		So(err, ShouldBeNil)
+		rs, _ := r.Streamer("test")
+		_, err = rs.New(nil)
+		So(err, ShouldResemble, errors.New("foo"))
+	})
+
+	Convey("Should reject invalid constructors", t, func() {
+		r := NewRegistry()
+		So(r.Register("a", "", "foo", func(string) Streamer { return nil }),
+			ShouldNotBeNil)
+		So(r.Register("a", "", testOpts{}, func(struct{}) Streamer {
+			return nil
+		}), ShouldNotBeNil)
+		So(r.Register("a", "", testOpts{}, func(testOpts) string {
+			return ""
+		}), ShouldNotBeNil)
+		So(r.Register("a", "", struct{ Foo []string }{},
+			func(struct{ Foo []string }) Streamer { return nil }),
+			ShouldNotBeNil)
+	})
+}
+
+func TestRegistryStreamers(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterStreamer("b", "B", nil, BoolParam("foo", false, "Foo"))
+	r.RegisterStreamer("a", "A", nil)
+
+	Convey("Should list Streamers sorted by name", t, func() {
+		rss := r.Streamers()
+		So(len(rss), ShouldEqual, 2)
+		So(rss[0].Name, ShouldEqual, "a")
+		So(rss[1].Name, ShouldEqual, "b")
+	})
+
+	Convey("Should write the list", t, func() {
+		var b bytes.Buffer
+		So(r.WriteList(&b), ShouldBeNil)
+		So(b.String(), ShouldEqual, "a  A\n"+
+			"b  B\n"+
+			"   foo=<bool>  Foo [default: false]\n")
+
+		b.Reset()
+		So(r.WriteListJSON(&b), ShouldBeNil)
+		var rss []RegisteredStreamer
+		So(json.Unmarshal(b.Bytes(), &rss), ShouldBeNil)
+		So(rss[1].Options[0].Name, ShouldEqual, "foo")
+	})
+}
+
+func TestSnakeCase(t *testing.T) {
+	Convey("Should convert field names", t, func() {
+		So(snakeCase("Ext"), ShouldEqual, "ext")
+		So(snakeCase("SkipDrafts"), ShouldEqual, "skip_drafts")
+		So(snakeCase("HTMLFile"), ShouldEqual, "html_file")
+		So(snakeCase("MaxID"), ShouldEqual, "max_id")
+	})
+}
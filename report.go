@@ -0,0 +1,78 @@
+package muta
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Report is a machine readable summary of a run, as written by
+// `muta --report <file>`.
+type Report struct {
+	Tasks    []TaskReport  `json:"tasks"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// TaskReport is the summary of a single task within a Report.
+type TaskReport struct {
+	Name         string        `json:"name"`
+	Status       TaskStatus    `json:"status"`
+	Duration     time.Duration `json:"duration"`
+	Error        string        `json:"error,omitempty"`
+	Streamer     string        `json:"streamer,omitempty"`
+	File         string        `json:"file,omitempty"`
+	Sources      []SrcStats    `json:"sources,omitempty"`
+	Destinations []DestStats   `json:"destinations,omitempty"`
+}
+
+// NewReport returns a Report of the given TaskResults, and the error
+// returned by the run.
+func NewReport(rs []TaskResult, d time.Duration, err error) Report {
+	r := Report{
+		Tasks:    make([]TaskReport, len(rs)),
+		Duration: d,
+		ExitCode: ExitCode(err),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for i, tr := range rs {
+		r.Tasks[i] = TaskReport{
+			Name:         tr.Name,
+			Status:       tr.Status,
+			Duration:     tr.Duration,
+			Streamer:     tr.Streamer,
+			File:         tr.File,
+			Sources:      tr.Sources,
+			Destinations: tr.Destinations,
+		}
+		if tr.Err != nil {
+			r.Tasks[i].Error = tr.Err.Error()
+		}
+	}
+	return r
+}
+
+// WriteJSON writes the Report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// collectStats records the stats of every SrcStreamer and DestStreamer
+// in the given Stream, including nested Streams, on the TaskResult.
+func collectStats(s Stream, r *TaskResult) {
+	for _, sr := range s {
+		switch v := sr.(type) {
+		case Stream:
+			collectStats(v, r)
+		case *SrcStreamer:
+			r.Sources = append(r.Sources, v.Stats())
+		case *DestStreamer:
+			r.Destinations = append(r.Destinations, v.Stats())
+		}
+	}
+}
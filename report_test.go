@@ -0,0 +1,54 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewReport(t *testing.T) {
+	Convey("Should summarize the TaskResults", t, func() {
+		err := errors.New("foo")
+		r := NewReport([]TaskResult{
+			{Name: "a", Status: TaskOK},
+			{Name: "b", Status: TaskFailed, Err: err, Streamer: "muta.Dest"},
+		}, time.Second, err)
+		So(r.ExitCode, ShouldEqual, ExitTaskFailed)
+		So(r.Error, ShouldEqual, "foo")
+		So(r.Duration, ShouldEqual, time.Second)
+		So(r.Tasks[1].Error, ShouldEqual, "foo")
+		So(r.Tasks[1].Streamer, ShouldEqual, "muta.Dest")
+
+		var b bytes.Buffer
+		So(r.WriteJSON(&b), ShouldBeNil)
+		var decoded Report
+		So(json.Unmarshal(b.Bytes(), &decoded), ShouldBeNil)
+		So(decoded.Tasks[0].Name, ShouldEqual, "a")
+	})
+
+	Convey("Should include the files read and written", t, func() {
+		dest := filepath.Join("_test", "tmp", "report")
+		defer os.RemoveAll(dest)
+
+		ta := NewTasker()
+		ta.Task("a", func() Stream {
+			return Src(filepath.Join("_test", "fixtures", "*.md")).
+				Pipe(Dest(dest))
+		})
+		rs, err := ta.RunTasks("a")
+		So(err, ShouldBeNil)
+
+		r := NewReport(rs, 0, err)
+		So(r.Tasks[0].Sources, ShouldResemble, []SrcStats{
+			{Base: filepath.Join("_test", "fixtures"), Files: 2}})
+		So(r.Tasks[0].Destinations[0].Destination, ShouldEqual, dest)
+		So(r.Tasks[0].Destinations[0].Files, ShouldEqual, 2)
+		So(r.Tasks[0].Destinations[0].Bytes, ShouldBeGreaterThan, 0)
+	})
+}
@@ -0,0 +1,82 @@
+package muta
+
+import (
+	"io"
+
+	"github.com/leeola/muta/mutil"
+)
+
+// MaxMemoryBuffer is the number of bytes Rewindable() will buffer in
+// memory. Content larger than this is spilled to a temporary file.
+var MaxMemoryBuffer int64 = 4 << 20
+
+// Rewinder is an optional interface for the io.ReadClosers passed
+// between Streamers, whose content can be cheaply read again from the
+// start.
+type Rewinder interface {
+	io.ReadCloser
+	Rewind() error
+}
+
+// BufferedStreamer is an optional interface for Streamers that need to
+// read incoming content more than once. If BufferInput() returns true,
+// the Stream passes the incoming io.ReadCloser through Rewindable()
+// before calling the Streamer, so it can be asserted to a Rewinder.
+type BufferedStreamer interface {
+	Streamer
+	BufferInput() bool
+}
+
+// Rewindable returns a Rewinder for the given io.ReadCloser.
+//
+// If the io.ReadCloser already implements Rewinder it is returned as
+// is, and if it implements io.Seeker (such as an *os.File) rewinding
+// seeks back to the current position. Otherwise, the content is read
+// into a buffer, spilling to a temporary file if it exceeds
+// MaxMemoryBuffer, and the original io.ReadCloser is Closed.
+func Rewindable(rc io.ReadCloser) (Rewinder, error) {
+	if rc == nil {
+		return nil, nil
+	}
+
+	if r, ok := rc.(Rewinder); ok {
+		return r, nil
+	}
+
+	if s, ok := rc.(io.Seeker); ok {
+		offset, err := s.Seek(0, io.SeekCurrent)
+		if err == nil {
+			return &seekRewinder{ReadCloser: rc, seeker: s, offset: offset}, nil
+		}
+	}
+
+	defer rc.Close()
+	return mutil.NewSpillBuffer(rc, MaxMemoryBuffer)
+}
+
+// bufferInput makes the given io.ReadCloser Rewindable if the Streamer
+// asks for it.
+func bufferInput(sr Streamer, rc io.ReadCloser) (io.ReadCloser, error) {
+	if rc == nil {
+		return rc, nil
+	}
+	if bs, ok := sr.(BufferedStreamer); !ok || !bs.BufferInput() {
+		return rc, nil
+	}
+	r, err := Rewindable(rc)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type seekRewinder struct {
+	io.ReadCloser
+	seeker io.Seeker
+	offset int64
+}
+
+func (r *seekRewinder) Rewind() error {
+	_, err := r.seeker.Seek(r.offset, io.SeekStart)
+	return err
+}
@@ -0,0 +1,89 @@
+package muta
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRewindable(t *testing.T) {
+	Convey("Should buffer plain readers in memory", t, func() {
+		r, err := Rewindable(mutil.StringCloser("foo"))
+		So(err, ShouldBeNil)
+		b, _ := ioutil.ReadAll(r)
+		So(string(b), ShouldEqual, "foo")
+		So(r.Rewind(), ShouldBeNil)
+		b, _ = ioutil.ReadAll(r)
+		So(string(b), ShouldEqual, "foo")
+		So(r.(*mutil.SpillBuffer).Spilled(), ShouldBeFalse)
+	})
+
+	Convey("Should spill large content to a temp file", t, func() {
+		defer func(m int64) { MaxMemoryBuffer = m }(MaxMemoryBuffer)
+		MaxMemoryBuffer = 2
+
+		r, err := Rewindable(mutil.StringCloser("foobar"))
+		So(err, ShouldBeNil)
+		sb := r.(*mutil.SpillBuffer)
+		So(sb.Spilled(), ShouldBeTrue)
+		So(sb.Size(), ShouldEqual, 6)
+		b, _ := ioutil.ReadAll(r)
+		So(string(b), ShouldEqual, "foobar")
+		So(r.Rewind(), ShouldBeNil)
+		b, _ = ioutil.ReadAll(r)
+		So(string(b), ShouldEqual, "foobar")
+		So(r.Close(), ShouldBeNil)
+	})
+
+	Convey("Should rewind seekable readers without buffering", t, func() {
+		f, err := os.Open(filepath.Join("_test", "fixtures", "hello"))
+		So(err, ShouldBeNil)
+		r, err := Rewindable(f)
+		So(err, ShouldBeNil)
+		defer r.Close()
+		_, ok := r.(*seekRewinder)
+		So(ok, ShouldBeTrue)
+		b, _ := ioutil.ReadAll(r)
+		So(r.Rewind(), ShouldBeNil)
+		b2, _ := ioutil.ReadAll(r)
+		So(string(b2), ShouldEqual, string(b))
+	})
+
+	Convey("Should return existing Rewinders as is", t, func() {
+		r, _ := Rewindable(mutil.StringCloser("foo"))
+		r2, err := Rewindable(r)
+		So(err, ShouldBeNil)
+		So(r2, ShouldEqual, r)
+	})
+}
+
+type rewindStreamer struct {
+	FuncStreamer
+}
+
+func (rewindStreamer) BufferInput() bool { return true }
+
+func TestStreamBufferedStreamer(t *testing.T) {
+	Convey("Should give BufferedStreamers a Rewinder", t, func() {
+		var ok bool
+		sr := rewindStreamer{func(fi FileInfo, rc io.ReadCloser) (
+			FileInfo, io.ReadCloser, error) {
+			_, ok = rc.(Rewinder)
+			return fi, rc, nil
+		}}
+
+		s := Stream{sr}
+		s.NextFrom(0, NewFileInfo("foo"), mutil.StringCloser("foo"))
+		So(ok, ShouldBeTrue)
+
+		ok = false
+		s.Trace(NewTracer()).NextFrom(0, NewFileInfo("foo"),
+			mutil.StringCloser("foo"))
+		So(ok, ShouldBeTrue)
+	})
+}
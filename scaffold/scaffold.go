@@ -0,0 +1,113 @@
+// # Scaffold
+//
+// Starter projects for `muta init`. Each Template is a set of files,
+// built into the binary, which are written into a new project
+// directory.
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The Template used when none is given to `muta init`
+const DefaultTemplate string = "hello"
+
+// A Template is a named set of files to scaffold a project with. File
+// paths are slash separated and relative to the project directory.
+type Template struct {
+	Name        string
+	Description string
+	Files       map[string]string
+}
+
+// Paths returns the sorted paths of the Template files.
+func (t Template) Paths() []string {
+	ps := make([]string, 0, len(t.Files))
+	for p := range t.Files {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// Returned by Init when a file of the Template already exists.
+type ExistsError struct {
+	Path string
+}
+
+func (e *ExistsError) Error() string {
+	return fmt.Sprintf("Refusing to overwrite existing file '%s'", e.Path)
+}
+
+// Templates returns all of the built in Templates, sorted by name.
+func Templates() []Template {
+	ts := make([]Template, 0, len(templates))
+	for _, t := range templates {
+		ts = append(ts, t)
+	}
+	sort.Sort(byName(ts))
+	return ts
+}
+
+// Lookup returns the built in Template with the given name.
+func Lookup(name string) (Template, error) {
+	t, ok := templates[name]
+	if !ok {
+		var ns []string
+		for _, t := range Templates() {
+			ns = append(ns, t.Name)
+		}
+		return Template{}, errors.New(fmt.Sprintf(
+			"Template '%s' not found, available templates are: %s",
+			name, strings.Join(ns, ", ")))
+	}
+	return t, nil
+}
+
+// Init writes the files of the named Template into dir, returning the
+// paths written.
+//
+// If any of the files already exist an *ExistsError is returned, and
+// nothing is written.
+func Init(dir, name string) ([]string, error) {
+	t, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var ps []string
+	for _, p := range t.Paths() {
+		fp := filepath.Join(dir, filepath.FromSlash(p))
+		if _, err := os.Lstat(fp); err == nil {
+			return nil, &ExistsError{fp}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		ps = append(ps, fp)
+	}
+
+	var written []string
+	for i, p := range t.Paths() {
+		fp := ps[i]
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			return written, err
+		}
+		if err := ioutil.WriteFile(fp, []byte(t.Files[p]), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, fp)
+	}
+	return written, nil
+}
+
+type byName []Template
+
+func (ts byName) Len() int           { return len(ts) }
+func (ts byName) Swap(i, j int)      { ts[i], ts[j] = ts[j], ts[i] }
+func (ts byName) Less(i, j int) bool { return ts[i].Name < ts[j].Name }
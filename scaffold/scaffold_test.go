@@ -0,0 +1,100 @@
+package scaffold
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplates(t *testing.T) {
+	Convey("Should include the default template", t, func() {
+		_, err := Lookup(DefaultTemplate)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Should be sorted by name", t, func() {
+		ts := Templates()
+		So(len(ts), ShouldEqual, len(templates))
+		for i := 1; i < len(ts); i++ {
+			So(ts[i-1].Name, ShouldBeLessThan, ts[i].Name)
+		}
+	})
+
+	Convey("Should generate a muta.go that compiles", t, func() {
+		// The source importer type checks muta itself, which is slow,
+		// so share it between the templates.
+		fset := token.NewFileSet()
+		imp := importer.ForCompiler(fset, "source", nil)
+		for _, tmpl := range Templates() {
+			src, ok := tmpl.Files["muta.go"]
+			So(ok, ShouldBeTrue)
+
+			f, err := parser.ParseFile(fset, tmpl.Name+"/muta.go", src, 0)
+			So(err, ShouldBeNil)
+			So(f.Name.Name, ShouldEqual, "main")
+
+			conf := types.Config{Importer: imp}
+			_, err = conf.Check(tmpl.Name, fset, []*ast.File{f}, nil)
+			So(err, ShouldBeNil)
+		}
+	})
+}
+
+func TestLookup(t *testing.T) {
+	Convey("Should list the available templates if not found", t, func() {
+		_, err := Lookup("foo")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "hello")
+	})
+}
+
+func TestInit(t *testing.T) {
+	Convey("Should write the template files", t, func() {
+		dir, err := ioutil.TempDir("", "muta-scaffold")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		ps, err := Init(dir, "markdown")
+		So(err, ShouldBeNil)
+		So(ps, ShouldResemble, []string{
+			filepath.Join(dir, "muta.go"),
+			filepath.Join(dir, "pages", "index.md"),
+		})
+		b, err := ioutil.ReadFile(filepath.Join(dir, "muta.go"))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, templates["markdown"].Files["muta.go"])
+	})
+
+	Convey("Should refuse to overwrite existing files", t, func() {
+		dir, err := ioutil.TempDir("", "muta-scaffold")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		p := filepath.Join(dir, "pages", "index.md")
+		So(os.MkdirAll(filepath.Dir(p), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(p, []byte("foo"), 0644), ShouldBeNil)
+
+		ps, err := Init(dir, "markdown")
+		So(ps, ShouldBeNil)
+		So(err, ShouldResemble, &ExistsError{p})
+
+		// Nothing should have been written
+		_, err = os.Stat(filepath.Join(dir, "muta.go"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+		b, _ := ioutil.ReadFile(p)
+		So(string(b), ShouldEqual, "foo")
+	})
+
+	Convey("Should return an error for unknown templates", t, func() {
+		_, err := Init(os.TempDir(), "foo")
+		So(err, ShouldNotBeNil)
+	})
+}
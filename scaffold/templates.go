@@ -0,0 +1,211 @@
+package scaffold
+
+var templates = map[string]Template{
+	"hello": {
+		Name:        "hello",
+		Description: "A minimal muta.go with a few dependent tasks",
+		Files: map[string]string{
+			"muta.go": helloMuta,
+		},
+	},
+	"markdown": {
+		Name:        "markdown",
+		Description: "A site built from Markdown pages into ./build",
+		Files: map[string]string{
+			"muta.go":        markdownMuta,
+			"pages/index.md": markdownIndex,
+		},
+	},
+	"assets": {
+		Name:        "assets",
+		Description: "An asset pipeline for styles, scripts and images",
+		Files: map[string]string{
+			"muta.go":             assetsMuta,
+			"assets/css/main.css": assetsCSS,
+			"assets/js/main.js":   assetsJS,
+		},
+	},
+}
+
+const helloMuta string = `package main
+
+import (
+	"fmt"
+
+	"github.com/leeola/muta"
+)
+
+func Hello() {
+	fmt.Println("Hello")
+}
+
+func main() {
+	// Add the "hello" task, with a func() handler
+	muta.Task("hello", muta.Desc("Say hello"), Hello)
+
+	// Add the "world" task, with the "hello" dependency
+	muta.Task("world", muta.Desc("Say hello world"), "hello", func() {
+		fmt.Println("World")
+	})
+
+	// Add the optional default task
+	muta.Task("default", "world")
+
+	// Run Te() or Start() to start Muta. Run "muta -h" for a task list.
+	muta.Te()
+}
+`
+
+const markdownMuta string = `package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mutil"
+)
+
+// Markdown converts *.md files into *.html files. It only understands
+// headings and paragraphs, replace it with a full Markdown Streamer as
+// the site grows.
+func Markdown() muta.Streamer {
+	return muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+		muta.FileInfo, io.ReadCloser, error) {
+		if fi == nil || filepath.Ext(fi.Name()) != ".md" {
+			return fi, rc, nil
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return fi, nil, err
+		}
+
+		var out bytes.Buffer
+		var para []string
+		flush := func() {
+			if len(para) > 0 {
+				fmt.Fprintf(&out, "<p>%s</p>\n", strings.Join(para, " "))
+				para = nil
+			}
+		}
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			switch {
+			case line == "":
+				flush()
+			case strings.HasPrefix(line, "#"):
+				flush()
+				n := len(line) - len(strings.TrimLeft(line, "#"))
+				if n > 6 {
+					n = 6
+				}
+				fmt.Fprintf(&out, "<h%d>%s</h%d>\n", n,
+					html.EscapeString(strings.TrimSpace(line[n:])), n)
+			default:
+				para = append(para, html.EscapeString(line))
+			}
+		}
+		flush()
+
+		fi.SetName(strings.TrimSuffix(fi.Name(), ".md") + ".html")
+		return fi, mutil.ByteCloser(out.Bytes()), nil
+	})
+}
+
+func main() {
+	muta.Task("site", muta.Desc("Build the pages into ./build"),
+		func() muta.Stream {
+			return muta.Src("./pages/*.md").
+				Pipe(Markdown()).
+				Pipe(muta.Dest("./build"))
+		})
+
+	muta.Task("default", "site")
+	muta.Te()
+}
+`
+
+const markdownIndex string = `# Hello
+
+This page was built by Muta, from pages/index.md.
+`
+
+const assetsMuta string = `package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/leeola/muta"
+	"github.com/leeola/muta/mutil"
+)
+
+// Trim removes leading and trailing whitespace and blank lines from
+// files with the given extension.
+func Trim(ext string) muta.Streamer {
+	return muta.FuncStreamer(func(fi muta.FileInfo, rc io.ReadCloser) (
+		muta.FileInfo, io.ReadCloser, error) {
+		if fi == nil || filepath.Ext(fi.Name()) != ext {
+			return fi, rc, nil
+		}
+		defer rc.Close()
+
+		var out bytes.Buffer
+		s := bufio.NewScanner(rc)
+		for s.Scan() {
+			if line := strings.TrimSpace(s.Text()); line != "" {
+				out.WriteString(line)
+				out.WriteString("\n")
+			}
+		}
+		if err := s.Err(); err != nil {
+			return fi, nil, err
+		}
+		return fi, mutil.ByteCloser(out.Bytes()), nil
+	})
+}
+
+func main() {
+	muta.Task("styles", muta.Desc("Build the stylesheets"),
+		func() muta.Stream {
+			return muta.Src("./assets/css/*.css").
+				Pipe(Trim(".css")).
+				Pipe(muta.Dest("./build/css"))
+		})
+
+	muta.Task("scripts", muta.Desc("Build the scripts"),
+		func() muta.Stream {
+			return muta.Src("./assets/js/*.js").
+				Pipe(Trim(".js")).
+				Pipe(muta.Dest("./build/js"))
+		})
+
+	muta.Task("images", muta.Desc("Copy the images"),
+		func() muta.Stream {
+			return muta.Src("./assets/images/*").
+				Pipe(muta.Dest("./build/images"))
+		})
+
+	muta.Task("default", "styles", "scripts", "images")
+	muta.Te()
+}
+`
+
+const assetsCSS string = `body {
+  font-family: sans-serif;
+}
+`
+
+const assetsJS string = `console.log("Hello from Muta");
+`
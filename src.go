@@ -5,8 +5,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/leeola/muta/logging"
 )
 
 const srcPluginName string = "muta.Src"
@@ -40,6 +38,22 @@ type SrcStreamer struct {
 
 	// The filepaths that this Streamer will load, and Stream
 	Sources []string
+
+	// The number of files opened so far
+	opened int
+
+	StreamLogger
+}
+
+// SrcStats are the number of files read by a SrcStreamer.
+type SrcStats struct {
+	Base  string `json:"base"`
+	Files int    `json:"files"`
+}
+
+// Stats returns the number of files this SrcStreamer has read.
+func (s *SrcStreamer) Stats() SrcStats {
+	return SrcStats{Base: s.Base, Files: s.opened}
 }
 
 func (s *SrcStreamer) init() *SrcStreamer {
@@ -76,6 +90,11 @@ func (s *SrcStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 			return nil, nil, err
 		}
 		s.Sources = append(expanded, s.Sources[1:]...)
+
+		// A glob matching no files is skipped
+		if len(expanded) == 0 {
+			return s.Next(nil, nil)
+		}
 	}
 
 	// Shift a path from the Sources slice
@@ -88,7 +107,7 @@ func (s *SrcStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 		fi.SetPath(".")
 	}
 
-	logging.Debug([]string{srcPluginName}, "Opening", p)
+	s.Logger().With("file", p).Debug([]string{srcPluginName}, "Opening")
 	// Open the file for reading
 	f, err := os.Open(p)
 
@@ -96,6 +115,7 @@ func (s *SrcStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 		return fi, f, err
 	}
 
+	s.opened++
 	return fi, f, nil
 }
 
@@ -122,3 +142,13 @@ func globsToBase(globs ...string) string {
 	}
 	return base
 }
+
+func (s *SrcStreamer) Describe() Description {
+	return Description{
+		Name: srcPluginName,
+		Options: map[string]interface{}{
+			"Base":    s.Base,
+			"Sources": s.Sources,
+		},
+	}
+}
@@ -43,6 +43,38 @@ func TestSrcStreamerNext(t *testing.T) {
 		})
 	})
 
+	Convey("With a glob matching no files", t, func() {
+		Convey("It should skip to the next source", func() {
+			s := &SrcStreamer{
+				Sources: []string{
+					filepath.Join(tmpDir, "*.none"),
+					filepath.Join(tmpDir, "hello"),
+				},
+			}
+			fi, r, err := s.Next(nil, nil)
+			So(err, ShouldBeNil)
+			So(fi.Name(), ShouldEqual, "hello")
+			r.Close()
+
+			fi, r, err = s.Next(nil, nil)
+			So(err, ShouldBeNil)
+			So(fi, ShouldBeNil)
+		})
+
+		Convey("It should return no file if no sources are left", func() {
+			s := &SrcStreamer{
+				Sources: []string{
+					filepath.Join(tmpDir, "*.none"),
+					filepath.Join(tmpDir, "*.nothing"),
+				},
+			}
+			fi, r, err := s.Next(nil, nil)
+			So(err, ShouldBeNil)
+			So(fi, ShouldBeNil)
+			So(r, ShouldBeNil)
+		})
+	})
+
 	Convey("With previous Streamers", t, func() {
 		Convey("the files should be loaded in order", func() {
 			s := Stream{
@@ -25,6 +25,9 @@ type Stream []Streamer
 // error on the first Next() call.
 func (s Stream) Pipe(sr Streamer) Stream {
 	if _, ok := sr.(error); ok {
+		if len(s) == 0 {
+			return Stream{sr}
+		}
 		s[0] = sr
 		return s[:1]
 	}
@@ -32,6 +35,23 @@ func (s Stream) Pipe(sr Streamer) Stream {
 	return append(s, sr)
 }
 
+// Wrap returns a copy of this Stream with every Streamer replaced by
+// the Streamer returned from fn. Nested Streams are not passed to fn,
+// instead their Streamers are wrapped recursively.
+//
+// This is useful for instrumenting a Stream, such as with Trace().
+func (s Stream) Wrap(fn func(Streamer) Streamer) Stream {
+	ws := make(Stream, len(s))
+	for i, sr := range s {
+		if ns, ok := sr.(Stream); ok {
+			ws[i] = ns.Wrap(fn)
+		} else {
+			ws[i] = fn(sr)
+		}
+	}
+	return ws
+}
+
 // Next satisifies the Streamer interface by providing any incoming
 // FileInfo and ReadCoser to all of the Streamer's contained in this
 // Stream.
@@ -46,6 +66,14 @@ func (s Stream) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
 //
 // If any Streamers return a nil file, no further Streamers are called.
 //
+// If a Streamer implements BufferedStreamer, the incoming io.ReadCloser
+// is made Rewindable before it is called.
+//
+// Since NextFrom can only return a single file, an Emitter producing
+// more than one file results in ErrMultipleFiles. The files of an
+// Emitter are counted before any of them are given to the rest of the
+// Stream, so that a Dest following it never writes them.
+//
 // NextFrom is mostly an implementation detail, but is public to allow you
 // to step through the slice at various points. Useful for debugging,
 // testing, etc.
@@ -55,7 +83,19 @@ func (s Stream) NextFrom(from int, inFi FileInfo, inRc io.ReadCloser) (
 	fi = inFi
 	rc = inRc
 	for ; from < len(s); from++ {
-		fi, rc, err = s[from].Next(fi, rc)
+		if rc, err = bufferInput(s[from], rc); err != nil {
+			return nil, nil, err
+		}
+
+		switch sr := s[from].(type) {
+		case Stream:
+			// A nested Stream returns a single file itself
+			fi, rc, err = sr.Next(fi, rc)
+		case Emitter:
+			fi, rc, err = emitOne(sr, fi, rc)
+		default:
+			fi, rc, err = sr.Next(fi, rc)
+		}
 
 		if err != nil {
 			return
@@ -68,6 +108,60 @@ func (s Stream) NextFrom(from int, inFi FileInfo, inRc io.ReadCloser) (
 	return
 }
 
+// Emit satisfies the Emitter interface by providing the incoming
+// FileInfo and ReadCloser to all of the Streamers contained in this
+// Stream, calling emit for every file that makes it through the entire
+// Stream.
+//
+// This allows a Stream containing Emitters to be Piped into another
+// Stream, without losing any of the emitted files.
+//
+// Given a nil FileInfo, every Streamer of the Stream is asked to generate
+// files in turn, as in Stream(), so that Streamers of a nested Stream
+// can generate files too.
+func (s Stream) Emit(fi FileInfo, rc io.ReadCloser, emit EmitFunc) error {
+	if fi == nil {
+		return s.generate(emit)
+	}
+	return s.EmitFrom(0, fi, rc, emit)
+}
+
+// EmitFrom behaves like NextFrom, but rather than returning the file at
+// the end of the Stream, it calls emit with it. Emitters within the
+// Stream may produce any number of files, each of which is piped through
+// the remaining Streamers independently.
+func (s Stream) EmitFrom(from int, fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) (err error) {
+
+	for ; from < len(s); from++ {
+		if rc, err = bufferInput(s[from], rc); err != nil {
+			return
+		}
+
+		if e, ok := s[from].(Emitter); ok {
+			next := from + 1
+			return e.Emit(fi, rc, func(efi FileInfo, erc io.ReadCloser) error {
+				if efi == nil {
+					return nil
+				}
+				return s.EmitFrom(next, efi, erc, emit)
+			})
+		}
+
+		fi, rc, err = s[from].Next(fi, rc)
+
+		if err != nil {
+			return
+		}
+
+		if fi == nil {
+			return
+		}
+	}
+
+	return emit(fi, rc)
+}
+
 // Stream calls all of the Streamer's until every Streamer has stopped
 // returning files.
 //
@@ -77,43 +171,107 @@ func (s Stream) NextFrom(from int, inFi FileInfo, inRc io.ReadCloser) (
 // returns a nil FileInfo. Once that happens, the next Streamer in the
 // slice is treated the same way.
 func (s Stream) Stream() (err error) {
-	var fi FileInfo
-	var rc io.ReadCloser
+	// Every file that makes it to the end of the Stream is Closed, to
+	// be safe.
+	return s.generate(func(_ FileInfo, rc io.ReadCloser) error {
+		if rc != nil {
+			return rc.Close()
+		}
+		return nil
+	})
+}
 
+// generate calls every Streamer with `nil,nil` until it stops returning
+// files, as described by Stream(), calling emit with every file that
+// makes it through the entire Stream.
+func (s Stream) generate(emit EmitFunc) (err error) {
 	for i := 0; i < len(s); i++ {
-		// Call the current Streamer
-		fi, rc, err = s[i].Next(nil, nil)
+		var emitted bool
 
-		if err != nil {
-			return err
-		}
-
-		// If the current Streamer returned a nil FileInfo, move onto the
-		// next Streamer.
-		if fi == nil {
-			continue
-		}
+		if e, ok := s[i].(Emitter); ok {
+			// Call the current Emitter, passing each emitted file onto all
+			// the other Streamers.
+			next := i + 1
+			err = e.Emit(nil, nil, func(fi FileInfo, rc io.ReadCloser) error {
+				if fi == nil {
+					return nil
+				}
+				emitted = true
+				return s.EmitFrom(next, fi, rc, emit)
+			})
+		} else {
+			// Call the current Streamer
+			var fi FileInfo
+			var rc io.ReadCloser
+			fi, rc, err = s[i].Next(nil, nil)
 
-		// Pass the Streamers return values onto all the other Streamers.
-		// Note that we're using the index+1, to ensure the Current Streamer
-		// isn't passed it's own returned file.
-		_, rc, err = s.NextFrom(i+1, fi, rc)
+			if err != nil {
+				return err
+			}
 
-		// Since the current Streamer returned a FileInfo, move the index back
-		// so that it is called again, and again, until it finally returns
-		// no more files.
-		i--
+			// If the current Streamer returned a nil FileInfo, move onto the
+			// next Streamer.
+			if fi == nil {
+				continue
+			}
 
-		// If an ReadCloser was returned, Close it to be safe.
-		if rc != nil {
-			rc.Close()
+			// Pass the Streamers return values onto all the other Streamers.
+			// Note that we're using the index+1, to ensure the Current
+			// Streamer isn't passed it's own returned file.
+			emitted = true
+			err = s.EmitFrom(i+1, fi, rc, emit)
 		}
 
 		// The other Streamers returned an error
 		if err != nil {
 			return err
 		}
+
+		// Since the current Streamer returned a FileInfo, move the index back
+		// so that it is called again, and again, until it finally returns
+		// no more files.
+		if emitted {
+			i--
+		}
 	}
 
 	return
 }
+
+// Close closes every Streamer of the Stream which implements io.Closer,
+// such as Streamers running external processes, returning the first
+// error. Stream tasks are closed by the Tasker once they finish, even if
+// they fail.
+func (s Stream) Close() error {
+	var err error
+	for _, sr := range s {
+		if c, ok := sr.(io.Closer); ok {
+			if cErr := c.Close(); err == nil {
+				err = cErr
+			}
+		}
+	}
+	return err
+}
+
+// callStreamer calls the given Streamer with the given file, emitting
+// the returned file if any. If the Streamer is an Emitter, Emit() is
+// called instead.
+func callStreamer(sr Streamer, fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) error {
+
+	rc, err := bufferInput(sr, rc)
+	if err != nil {
+		return err
+	}
+
+	if e, ok := sr.(Emitter); ok {
+		return e.Emit(fi, rc, emit)
+	}
+
+	fi, rc, err = sr.Next(fi, rc)
+	if err != nil || fi == nil {
+		return err
+	}
+	return emit(fi, rc)
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/leeola/muta/mutil"
@@ -31,6 +33,13 @@ func TestStreamPipe(t *testing.T) {
 			So(len(s), ShouldEqual, 1)
 			So(s[0], ShouldEqual, err)
 		})
+
+		Convey("contain only the Error, if empty", func() {
+			err := &ErrorStreamer{}
+			s := Stream{}.Pipe(err)
+			So(len(s), ShouldEqual, 1)
+			So(s[0], ShouldEqual, err)
+		})
 	})
 }
 
@@ -211,3 +220,199 @@ func TestStreamStream(t *testing.T) {
 		So(argFi, ShouldNotEqual, retFi)
 	})
 }
+
+func TestStreamEmitFrom(t *testing.T) {
+	double := FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+		emit EmitFunc) error {
+		if fi == nil {
+			return nil
+		}
+		if err := emit(fi, rc); err != nil {
+			return err
+		}
+		return emit(NewFileInfo(fi.Name()+".gz"), mutil.StringCloser("gz"))
+	})
+
+	Convey("Should pass each emitted file to the remaining Streamers", t, func() {
+		names := []string{}
+		s := Stream{
+			double,
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				names = append(names, fi.Name())
+				return fi, rc, nil
+			}),
+		}
+
+		emitted := 0
+		err := s.EmitFrom(0, NewFileInfo("foo"), nil,
+			func(FileInfo, io.ReadCloser) error {
+				emitted++
+				return nil
+			})
+		So(err, ShouldBeNil)
+		So(emitted, ShouldEqual, 2)
+		So(names, ShouldResemble, []string{"foo", "foo.gz"})
+	})
+
+	Convey("Should drop the file if nothing is emitted", t, func() {
+		called := false
+		s := Stream{
+			FuncEmitter(func(FileInfo, io.ReadCloser, EmitFunc) error {
+				return nil
+			}),
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				called = true
+				return fi, rc, nil
+			}),
+		}
+		err := s.EmitFrom(0, NewFileInfo("foo"), nil,
+			func(FileInfo, io.ReadCloser) error { return nil })
+		So(err, ShouldBeNil)
+		So(called, ShouldBeFalse)
+	})
+
+	Convey("Should return errors from the remaining Streamers", t, func() {
+		s := Stream{
+			double,
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				return nil, nil, errors.New("foo")
+			}),
+		}
+		err := s.EmitFrom(0, NewFileInfo("foo"), nil,
+			func(FileInfo, io.ReadCloser) error { return nil })
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "foo")
+	})
+
+	Convey("NextFrom should error if multiple files are emitted", t, func() {
+		s := Stream{double}
+		_, _, err := s.NextFrom(0, NewFileInfo("foo"), nil)
+		So(err, ShouldEqual, ErrMultipleFiles)
+	})
+
+	Convey("NextFrom should not pass on any of multiple emitted files", t,
+		func() {
+			tmpDir := filepath.Join("_test", "tmp", "multiple")
+			os.RemoveAll(tmpDir)
+			defer os.RemoveAll(tmpDir)
+
+			s := Stream{double, Dest(tmpDir)}
+			_, _, err := s.NextFrom(0, NewFileInfo("foo"),
+				mutil.StringCloser("foo"))
+			So(err, ShouldEqual, ErrMultipleFiles)
+			_, err = os.Stat(filepath.Join(tmpDir, "foo"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+	Convey("NextFrom should call nested Streams one file at a time", t,
+		func() {
+			s := Stream{Stream{&MockStreamer{Files: []string{"a", "b"}}}}
+			fi, rc, err := s.NextFrom(0, nil, nil)
+			So(err, ShouldBeNil)
+			So(fi.Name(), ShouldEqual, "a")
+			rc.Close()
+
+			fi, rc, err = s.NextFrom(0, nil, nil)
+			So(err, ShouldBeNil)
+			So(fi.Name(), ShouldEqual, "b")
+			rc.Close()
+		})
+
+	Convey("NextFrom should pass on a single emitted file", t, func() {
+		single := FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+			emit EmitFunc) error {
+			return emit(fi, rc)
+		})
+		s := Stream{single, FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+			FileInfo, io.ReadCloser, error) {
+			fi.SetName("bar")
+			return fi, rc, nil
+		})}
+		fi, _, err := s.NextFrom(0, NewFileInfo("foo"), nil)
+		So(err, ShouldBeNil)
+		So(fi.Name(), ShouldEqual, "bar")
+	})
+
+	Convey("Nested Streams should not lose emitted files", t, func() {
+		emitted := 0
+		s := Stream{Stream{double}, Stream{double}}
+		err := s.EmitFrom(0, NewFileInfo("foo"), nil,
+			func(FileInfo, io.ReadCloser) error {
+				emitted++
+				return nil
+			})
+		So(err, ShouldBeNil)
+		So(emitted, ShouldEqual, 4)
+	})
+}
+
+func TestStreamStreamEmitters(t *testing.T) {
+	Convey("Should stream every emitted file to the end", t, func() {
+		names := []string{}
+		s := Src(filepath.Join("_test", "fixtures", "hello")).
+			Pipe(FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+				emit EmitFunc) error {
+				if fi == nil {
+					return nil
+				}
+				defer rc.Close()
+				b, err := ioutil.ReadAll(rc)
+				if err != nil {
+					return err
+				}
+				for _, ext := range []string{".a", ".b", ".c"} {
+					efi := NewFileInfo(fi.Name() + ext)
+					if err := emit(efi, mutil.ByteCloser(b)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})).
+			Pipe(FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				if fi != nil {
+					names = append(names, fi.Name())
+				}
+				return fi, rc, nil
+			}))
+
+		err := s.Stream()
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, []string{"hello.a", "hello.b", "hello.c"})
+	})
+}
+
+type closeStreamer struct {
+	MockStreamer
+	closed bool
+}
+
+func (s *closeStreamer) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestStreamClose(t *testing.T) {
+	Convey("Should close every io.Closer Streamer", t, func() {
+		a, b := &closeStreamer{}, &closeStreamer{}
+		s := Stream{a, &MockStreamer{}, Stream{b}}
+		So(s.Close(), ShouldBeNil)
+		So(a.closed, ShouldBeTrue)
+		So(b.closed, ShouldBeTrue)
+	})
+
+	Convey("Should be called by the Tasker", t, func() {
+		a := &closeStreamer{}
+		ta := NewTasker()
+		ta.Task("a", func() Stream {
+			return Stream{&MockStreamer{Files: []string{"foo"}},
+				&ErrorStreamer{"foo"}, a}
+		})
+		_, err := ta.RunTasks("a")
+		So(err, ShouldNotBeNil)
+		So(a.closed, ShouldBeTrue)
+	})
+}
@@ -14,6 +14,27 @@ type Streamer interface {
 	Next(FileInfo, io.ReadCloser) (FileInfo, io.ReadCloser, error)
 }
 
+// EmitFunc is given to an Emitter, and is called once for every file the
+// Emitter produces. Each call passes the file onto the remaining
+// Streamers of the Stream, returning any error they return.
+type EmitFunc func(FileInfo, io.ReadCloser) error
+
+// Emitter is an optional interface for Streamers that produce more than
+// one file for a single incoming file. For example, writing both
+// `foo.html` and `foo.html.gz`, or splitting a sprite sheet into many
+// images.
+//
+// When a Stream encounters an Emitter, Emit() is called in place of
+// Next(), and every emitted file continues down the remaining Streamers
+// independently. Emitting nothing drops the incoming file.
+//
+// As with Next(), if Emit() is called with a nil FileInfo it may emit
+// new files, and it will be called again until it emits no files.
+type Emitter interface {
+	Streamer
+	Emit(FileInfo, io.ReadCloser, EmitFunc) error
+}
+
 // FileInfo is the base interface for getting and setting file info
 // for the given file.
 type FileInfo interface {
@@ -6,7 +6,14 @@ package muta
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
 
 	"github.com/leeola/muta/logging"
 )
@@ -15,6 +22,27 @@ type Handler func()
 type ErrorHandler func() error
 type ContextHandler func(Ctx *interface{}) error
 type StreamHandler func() Stream
+type ParamsHandler func(Params) error
+
+// A TaskOption modifies a task as it is registered. Any number of
+// TaskOptions can be given to Task(), such as Desc() and Hidden().
+type TaskOption func(*TaskerTask)
+
+// Desc returns a TaskOption setting the description of the task, as
+// shown by `muta --list` and `muta -h`.
+func Desc(d string) TaskOption {
+	return func(t *TaskerTask) {
+		t.Description = d
+	}
+}
+
+// Hidden returns a TaskOption that hides the task from `muta --list`
+// and `muta -h`. Hidden tasks can still be run by name.
+func Hidden() TaskOption {
+	return func(t *TaskerTask) {
+		t.Hidden = true
+	}
+}
 
 var DefaultTasker *Tasker = NewTasker()
 
@@ -28,23 +56,86 @@ func Run() {
 
 func NewTasker() *Tasker {
 	return &Tasker{
-		Tasks:  make(map[string]*TaskerTask),
-		Logger: logging.DefaultLogger(),
+		Tasks:    make(map[string]*TaskerTask),
+		Logger:   logging.DefaultLogger(),
+		Config:   DefaultConfig(),
+		Registry: DefaultRegistry,
 	}
 }
 
 type Tasker struct {
 	Tasks  map[string]*TaskerTask
 	Logger *logging.Logger
+
+	// If not nil, every Stream task is traced with this Tracer. See
+	// Stream.Trace() for details.
+	Tracer *Tracer
+
+	// If not nil, every task and the Streamers of every Stream task are
+	// profiled with this Profiler. See Stream.Profile() for details.
+	Profiler *Profiler
+
+	// If not nil, the progress of every running task is drawn with this
+	// Progress. Main() sets this when writing to a terminal.
+	Progress *Progress
+
+	// The number of tasks run at once. Tasks are started as soon as
+	// all of their dependencies have completed, so that independent
+	// dependencies, such as building scripts and styles, overlap. The
+	// tasks given to RunTasks are still run in order, each after the
+	// one before it, so that `muta clean build` never builds while
+	// cleaning. A value of 0 or 1 runs every task in order, one at a
+	// time.
+	Jobs int
+
+	// The Registry that the Streamers of pipeline files are built from,
+	// see LoadPipelines(). DefaultRegistry is used if nil.
+	Registry *Registry
+
+	// The defaults for the CLI options of Main(). Te() loads this from
+	// the config file and environment, see LoadConfig().
+	Config Config
+
+	// Set to 1 by Interrupt(), atomically.
+	interrupted int32
+
+	// The Commands running, killed by Interrupt(). Guarded by mu.
+	mu       sync.Mutex
+	commands map[*exec.Cmd]bool
 }
 
 type TaskerTask struct {
 	Name           string
+	Description    string
+	Hidden         bool
 	Dependencies   []string
+	Params         []Param
 	Handler        Handler
 	ErrorHandler   ErrorHandler
 	StreamHandler  StreamHandler
 	ContextHandler ContextHandler
+	ParamsHandler  ParamsHandler
+	Command        *Command
+}
+
+// handlerName returns a short name for the type of handler this
+// task uses.
+func (t *TaskerTask) handlerName() string {
+	switch {
+	case t.Handler != nil:
+		return "func"
+	case t.ErrorHandler != nil:
+		return "error"
+	case t.ContextHandler != nil:
+		return "context"
+	case t.StreamHandler != nil:
+		return "stream"
+	case t.ParamsHandler != nil:
+		return "params"
+	case t.Command != nil:
+		return "command"
+	}
+	return "none"
 }
 
 func (tr *Tasker) Task(n string, args ...interface{}) error {
@@ -53,12 +144,16 @@ func (tr *Tasker) Task(n string, args ...interface{}) error {
 	}
 
 	ds := []string{}
+	ps := []Param{}
+	opts := []TaskOption{}
 
 	var (
 		h  Handler
 		er ErrorHandler
 		sh StreamHandler
 		ch ContextHandler
+		ph ParamsHandler
+		c  *Command
 	)
 
 	for _, arg := range args {
@@ -80,6 +175,18 @@ func (tr *Tasker) Task(n string, args ...interface{}) error {
 		case "func(*interface {}) error":
 			ch = v.Interface().(func(*interface{}) error)
 			break
+		case "func(muta.Params) error":
+			ph = v.Interface().(func(Params) error)
+			break
+		case "muta.Command":
+			cmd := v.Interface().(Command)
+			c = &cmd
+		case "*muta.Command":
+			c = v.Interface().(*Command)
+		case "muta.Param":
+			ps = append(ps, v.Interface().(Param))
+		case "muta.TaskOption":
+			opts = append(opts, v.Interface().(TaskOption))
 		default:
 			return errors.New(fmt.Sprintf(
 				"unsupported task argument type '%s'", v.Type().String(),
@@ -87,30 +194,314 @@ func (tr *Tasker) Task(n string, args ...interface{}) error {
 		}
 	}
 
-	tr.Tasks[n] = &TaskerTask{
+	t := &TaskerTask{
 		Name:           n,
 		Dependencies:   ds,
+		Params:         ps,
 		Handler:        h,
 		ErrorHandler:   er,
 		StreamHandler:  sh,
 		ContextHandler: ch,
+		ParamsHandler:  ph,
+		Command:        c,
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	tr.Tasks[n] = t
 
 	return nil
 }
 
+// TaskStatus is the outcome of a single task run by RunTasks.
+type TaskStatus string
+
+const (
+	TaskOK          TaskStatus = "ok"
+	TaskFailed      TaskStatus = "failed"
+	TaskSkipped     TaskStatus = "skipped"
+	TaskInterrupted TaskStatus = "interrupted"
+)
+
+// TaskResult is the outcome and duration of a single task run by
+// RunTasks.
+type TaskResult struct {
+	Name     string
+	Status   TaskStatus
+	Duration time.Duration
+	Err      error
+
+	// If Err was returned by a Streamer, the name of the Streamer and
+	// the file it was given.
+	Streamer string
+	File     string
+
+	// The files read by each Src, and written by each Dest, of a Stream
+	// task.
+	Sources      []SrcStats
+	Destinations []DestStats
+}
+
 func (tr *Tasker) Run() error {
 	return tr.RunTask("default")
 }
 
-func (tr *Tasker) RunTask(tn string) (err error) {
-	t := tr.Tasks[tn]
-	if t == nil {
-		return errors.New(fmt.Sprintf("Task \"%s\" does not exist.", tn))
+// RunTask runs the given task, after running all of its dependencies.
+func (tr *Tasker) RunTask(tn string) error {
+	_, err := tr.RunTasks(tn)
+	return err
+}
+
+// RunTasks runs the given tasks in order, as a single graph. Every
+// dependency is run before the tasks that depend on it, and each task is
+// run at most once, no matter how many tasks depend on it.
+//
+// If Jobs is greater than one, the dependencies of a task which do not
+// depend on each other may be run at the same time. The given tasks
+// are still run in order.
+//
+// If any task returns an error, no further tasks are run and the error
+// is returned. A TaskResult is returned for every task in the graph,
+// including those that were skipped.
+func (tr *Tasker) RunTasks(tns ...string) ([]TaskResult, error) {
+	return tr.RunTasksWithParams(tns, nil)
+}
+
+// RunTasksWithParams behaves like RunTasks, but also gives each of the
+// named tasks the given Params. Tasks not in the map, including all
+// dependencies, use the defaults of their Params.
+//
+// All Params are validated before any task is run.
+func (tr *Tasker) RunTasksWithParams(tns []string,
+	ps map[string]Params) ([]TaskResult, error) {
+
+	plan, err := tr.plan(tns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tr.validateParams(ps); err != nil {
+		return nil, err
+	}
+
+	results := make([]TaskResult, len(plan))
+	for i, tn := range plan {
+		results[i].Name = tn
+		results[i].Status = TaskSkipped
+	}
+
+	atomic.StoreInt32(&tr.interrupted, 0)
+	if tr.Jobs > 1 {
+		return results, tr.runJobs(tns, plan, ps, results)
+	}
+
+	for i, tn := range plan {
+		if tr.isInterrupted() {
+			results[i].Status = TaskInterrupted
+			return results, ErrInterrupted
+		}
+
+		if err := tr.runResult(tr.Tasks[tn], ps[tn], &results[i]); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// runJobs runs the planned tasks, up to tr.Jobs at once. A task is
+// started once all of its dependencies are complete, and every given
+// task before it, in tns, is complete. After the first error no further
+// tasks are started, and the running tasks are waited for.
+func (tr *Tasker) runJobs(tns, plan []string, ps map[string]Params,
+	results []TaskResult) error {
+
+	index := make(map[string]int, len(plan))
+	for i, tn := range plan {
+		index[tn] = i
+	}
+
+	// The plan is made of the dependencies of each given task in turn,
+	// ending with the task itself, so the tasks planned for a given task
+	// wait for the given task before them. Given tasks already planned,
+	// as dependencies of earlier ones, add nothing to wait for.
+	end := make([]bool, len(plan))
+	last := -1
+	for _, tn := range tns {
+		if i := index[tn]; i > last {
+			end[i] = true
+			last = i
+		}
+	}
+	after := make([]int, len(plan))
+	last = -1
+	for i := range plan {
+		after[i] = last
+		if end[i] {
+			last = i
+		}
+	}
+	started := make([]bool, len(plan))
+	done := make([]bool, len(plan))
+	finished := make(chan int)
+
+	ready := func(i int) bool {
+		if after[i] > -1 && !done[after[i]] {
+			return false
+		}
+		for _, d := range tr.Tasks[plan[i]].Dependencies {
+			if !done[index[d]] {
+				return false
+			}
+		}
+		return true
 	}
 
+	var firstErr error
+	running := 0
+	for {
+		for i := 0; i < len(plan) && firstErr == nil &&
+			running < tr.Jobs; i++ {
+			if started[i] || !ready(i) {
+				continue
+			}
+			if tr.isInterrupted() {
+				results[i].Status = TaskInterrupted
+				firstErr = ErrInterrupted
+				break
+			}
+
+			started[i] = true
+			running++
+			go func(i int) {
+				tr.runResult(tr.Tasks[plan[i]], ps[plan[i]], &results[i])
+				finished <- i
+			}(i)
+		}
+
+		if running == 0 {
+			return firstErr
+		}
+		i := <-finished
+		running--
+		done[i] = true
+		if results[i].Err != nil && firstErr == nil {
+			firstErr = results[i].Err
+		}
+	}
+}
+
+// runResult runs the task, recording its result in r, and returns the
+// error of the task.
+func (tr *Tasker) runResult(t *TaskerTask, ps Params, r *TaskResult) error {
+	var tp *taskProgress
+	if tr.Progress != nil {
+		tp = tr.Progress.begin(t.Name)
+	}
+
+	start := time.Now()
+	err := tr.runTask(t, ps, r, tp)
+	r.Duration = time.Since(start)
+	r.Err = err
+	if se, ok := err.(*StreamerError); ok {
+		r.Streamer = se.Streamer
+		r.File = se.File
+	}
+	switch {
+	case err == ErrInterrupted:
+		r.Status = TaskInterrupted
+	case err != nil:
+		r.Status = TaskFailed
+	default:
+		r.Status = TaskOK
+	}
+
+	if tp != nil {
+		tr.Progress.end(tp, r)
+	}
+	return err
+}
+
+// Interrupt stops the current run as soon as possible. No further tasks
+// are started, Stream tasks stop before calling their next Streamer, and
+// running Commands are killed, returning ErrInterrupted.
+func (tr *Tasker) Interrupt() {
+	atomic.StoreInt32(&tr.interrupted, 1)
+	tr.killCommands()
+}
+
+// killCommands kills every running Command, along with the processes
+// they started.
+func (tr *Tasker) killCommands() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for cmd := range tr.commands {
+		cmd.Cancel()
+	}
+}
+
+func (tr *Tasker) isInterrupted() bool {
+	return atomic.LoadInt32(&tr.interrupted) == 1
+}
+
+// plan returns the order in which the given tasks and all of their
+// dependencies should be run, without duplicates. An error is returned
+// if any task does not exist, or if there are circular dependencies.
+func (tr *Tasker) plan(tns []string) ([]string, error) {
+	var plan []string
+	planned := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(tn string, path []string) error
+	visit = func(tn string, path []string) error {
+		if planned[tn] {
+			return nil
+		}
+		path = append(path, tn)
+		if visiting[tn] {
+			return &CycleError{path}
+		}
+
+		t := tr.Tasks[tn]
+		if t == nil {
+			return &TaskNotFoundError{tn}
+		}
+
+		visiting[tn] = true
+		for _, d := range t.Dependencies {
+			if err := visit(d, path); err != nil {
+				return err
+			}
+		}
+		visiting[tn] = false
+
+		planned[tn] = true
+		plan = append(plan, tn)
+		return nil
+	}
+
+	for _, tn := range tns {
+		if err := visit(tn, nil); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// runTask runs the handler of a single task, without its dependencies.
+// The statistics of Stream tasks are recorded on the given TaskResult.
+func (tr *Tasker) runTask(t *TaskerTask, ps Params, r *TaskResult,
+	tp *taskProgress) (err error) {
+
+	tn := t.Name
+
 	tr.Logger.Info([]string{"Task"}, tn, "starting")
+	start := time.Now()
 	defer func() {
+		if tr.Profiler != nil {
+			tr.Profiler.AddTask(tn, time.Since(start))
+		}
+
 		if err != nil {
 			tr.Logger.Error([]string{"Task"}, tn,
 				"returned an Error:", err)
@@ -119,12 +510,6 @@ func (tr *Tasker) RunTask(tn string) (err error) {
 		}
 	}()
 
-	if t.Dependencies != nil {
-		for _, d := range t.Dependencies {
-			tr.RunTask(d)
-		}
-	}
-
 	switch {
 	case t.Handler != nil:
 		t.Handler()
@@ -135,14 +520,120 @@ func (tr *Tasker) RunTask(tn string) (err error) {
 	case t.ContextHandler != nil:
 		return errors.New("Not implemented")
 
+	case t.ParamsHandler != nil:
+		return t.ParamsHandler(t.params(ps))
+
+	case t.Command != nil:
+		return tr.runCommand(tn, *t.Command)
+
 	case t.StreamHandler != nil:
 		s := t.StreamHandler()
 		if s == nil {
 			return nil
 		}
+		s.setLoggers(tr.Logger.With("task", tn))
+		defer collectStats(s, r)
+		defer func(s Stream) {
+			if cErr := s.Close(); err == nil {
+				err = cErr
+			}
+		}(s)
+
+		s = s.Wrap(func(sr Streamer) Streamer {
+			return &taskStreamer{Streamer: sr, tr: tr, progress: tp}
+		})
+
+		if tr.Tracer != nil {
+			s = s.Trace(tr.Tracer)
+		}
+
+		if tr.Profiler != nil {
+			s = s.Profile(tr.Profiler, tn)
+		}
 
 		return s.Stream()
 	}
 
 	return nil
 }
+
+// WriteTaskSummary writes the status and duration of each of the given
+// TaskResults as a table.
+func WriteTaskSummary(w io.Writer, rs []TaskResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range rs {
+		if r.Status == TaskSkipped {
+			fmt.Fprintf(tw, "  %s\t%s\t\n", r.Name, r.Status)
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", r.Name, r.Status, r.Duration)
+	}
+	return tw.Flush()
+}
+
+// taskStreamer wraps every Streamer of a Stream task, stopping the
+// Stream when the Tasker is interrupted, recording the origin of any
+// errors, and counting the files read and written for the Progress.
+type taskStreamer struct {
+	Streamer
+	tr       *Tasker
+	progress *taskProgress
+}
+
+func (s *taskStreamer) Describe() Description {
+	return Describe(s.Streamer)
+}
+
+func (s *taskStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	return emitOne(s, fi, rc)
+}
+
+func (s *taskStreamer) Emit(fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) error {
+
+	if s.tr.isInterrupted() {
+		if rc != nil {
+			rc.Close()
+		}
+		return ErrInterrupted
+	}
+
+	// Errors returned from emit have already been wrapped by the
+	// following Streamers.
+	var emitErr error
+	err := callStreamer(s.Streamer, fi, rc,
+		func(efi FileInfo, erc io.ReadCloser) error {
+			if efi != nil {
+				s.count()
+			}
+			emitErr = emit(efi, erc)
+			return emitErr
+		})
+
+	if err == nil || err == emitErr || err == ErrInterrupted {
+		return err
+	}
+	if _, ok := err.(*StreamerError); ok {
+		return err
+	}
+
+	se := &StreamerError{Streamer: Describe(s.Streamer).Name, Err: err}
+	if fi != nil {
+		se.File = filepath.Join(fi.Path(), fi.Name())
+	}
+	return se
+}
+
+// count a file passed on by the Streamer, if it is read or written.
+func (s *taskStreamer) count() {
+	if s.progress == nil {
+		return
+	}
+	switch s.Streamer.(type) {
+	case *SrcStreamer:
+		atomic.AddInt64(&s.progress.read, 1)
+	case *DestStreamer:
+		atomic.AddInt64(&s.progress.written, 1)
+	}
+}
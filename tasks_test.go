@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/leeola/muta/logging"
 	. "github.com/smartystreets/goconvey/convey"
@@ -54,6 +56,14 @@ func TestTaskerTask(t *testing.T) {
 		So(ta.Tasks["a"].ContextHandler, ShouldEqual, task)
 	})
 
+	Convey("Should apply TaskOptions", t, func() {
+		ta := NewTasker()
+		err := ta.Task("a", Desc("Does a"), Hidden(), func() {})
+		So(err, ShouldBeNil)
+		So(ta.Tasks["a"].Description, ShouldEqual, "Does a")
+		So(ta.Tasks["a"].Hidden, ShouldBeTrue)
+	})
+
 	Convey("Should not allow replacing tasks", t, func() {
 		ta := NewTasker()
 		err := ta.Task("a", []string{}, func() {})
@@ -142,9 +152,26 @@ func TestTaskerRunTask(t *testing.T) {
 	})
 
 	Convey("Should error on circular dependencies like", t, func() {
-		Convey("a[a]", nil)
-		Convey("a[b], b[a]", nil)
-		Convey("a[b], b[c], c[a]", nil)
+		Convey("a[a]", func() {
+			ta := NewTasker()
+			ta.Task("a", "a")
+			So(ta.RunTask("a"), ShouldNotBeNil)
+		})
+		Convey("a[b], b[a]", func() {
+			ta := NewTasker()
+			ta.Task("a", "b")
+			ta.Task("b", "a")
+			So(ta.RunTask("a"), ShouldNotBeNil)
+		})
+		Convey("a[b], b[c], c[a]", func() {
+			ta := NewTasker()
+			ta.Task("a", "b")
+			ta.Task("b", "c")
+			ta.Task("c", "a")
+			err := ta.RunTask("a")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "a -> b -> c -> a")
+		})
 	})
 
 	Convey("Should log tasks to the Tasker's Logger", t, func() {
@@ -172,3 +199,148 @@ func TestTaskerRunTask(t *testing.T) {
 		})
 	})
 }
+
+func TestTaskerRunTasks(t *testing.T) {
+	Convey("Should run tasks in order, deduplicating dependencies", t, func() {
+		called := []string{}
+		ta := NewTasker()
+		add := func(n string, deps ...string) {
+			ta.Task(n, deps, func() {
+				called = append(called, n)
+			})
+		}
+		add("clean")
+		add("compile", "clean")
+		add("build", "compile")
+		add("test", "compile")
+
+		rs, err := ta.RunTasks("clean", "build", "test")
+		So(err, ShouldBeNil)
+		So(called, ShouldResemble, []string{
+			"clean", "compile", "build", "test"})
+		So(len(rs), ShouldEqual, 4)
+		for _, r := range rs {
+			So(r.Status, ShouldEqual, TaskOK)
+		}
+	})
+
+	Convey("Should not run any tasks if one does not exist", t, func() {
+		called := false
+		ta := NewTasker()
+		ta.Task("a", func() { called = true })
+		_, err := ta.RunTasks("a", "b")
+		So(err, ShouldNotBeNil)
+		So(called, ShouldBeFalse)
+	})
+
+	Convey("Should stop and skip remaining tasks on error", t, func() {
+		called := false
+		ta := NewTasker()
+		ta.Task("a", func() error { return errors.New("a failed") })
+		ta.Task("b", func() { called = true })
+		rs, err := ta.RunTasks("a", "b")
+		So(err, ShouldNotBeNil)
+		So(called, ShouldBeFalse)
+		So(rs[0].Status, ShouldEqual, TaskFailed)
+		So(rs[0].Err, ShouldEqual, err)
+		So(rs[1].Status, ShouldEqual, TaskSkipped)
+	})
+
+	Convey("Should not run a task if its dependency fails", t, func() {
+		called := false
+		ta := NewTasker()
+		ta.Task("a", "b", func() { called = true })
+		ta.Task("b", func() error { return errors.New("b failed") })
+		err := ta.RunTask("a")
+		So(err, ShouldNotBeNil)
+		So(called, ShouldBeFalse)
+	})
+}
+
+func TestWriteTaskSummary(t *testing.T) {
+	Convey("Should write the status of each task", t, func() {
+		var b bytes.Buffer
+		WriteTaskSummary(&b, []TaskResult{
+			{Name: "a", Status: TaskOK},
+			{Name: "b", Status: TaskSkipped},
+		})
+		So(b.String(), ShouldContainSubstring, "a  ok")
+		So(b.String(), ShouldContainSubstring, "b  skipped")
+	})
+}
+
+func TestTaskerJobs(t *testing.T) {
+	Convey("Should run independent tasks at the same time", t, func() {
+		// The tasks wait for each other, so would block forever if run
+		// one at a time.
+		a, b := make(chan bool), make(chan bool)
+		ta := NewTasker()
+		ta.Jobs = 2
+		ta.Task("a", func() { a <- true; <-b })
+		ta.Task("b", func() { <-a; b <- true })
+		ta.Task("c", []string{"a", "b"}, func() {})
+		rs, err := ta.RunTasks("c")
+		So(err, ShouldBeNil)
+		So(rs[0].Status, ShouldEqual, TaskOK)
+		So(rs[1].Status, ShouldEqual, TaskOK)
+	})
+
+	Convey("Should run the given tasks in order", t, func() {
+		var mu sync.Mutex
+		order := []string{}
+		run := func(n string) func() {
+			return func() {
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				order = append(order, n)
+				mu.Unlock()
+			}
+		}
+		ta := NewTasker()
+		ta.Jobs = 4
+		ta.Task("clean", run("clean"))
+		ta.Task("scripts", run("scripts"))
+		ta.Task("styles", run("styles"))
+		ta.Task("build", []string{"scripts", "styles"}, run("build"))
+		ta.Task("deploy", "build", run("deploy"))
+		_, err := ta.RunTasks("clean", "build", "clean", "deploy")
+		So(err, ShouldBeNil)
+		So(order[0], ShouldEqual, "clean")
+		So(order[1:3], ShouldContain, "scripts")
+		So(order[1:3], ShouldContain, "styles")
+		So(order[3:], ShouldResemble, []string{"build", "deploy"})
+	})
+
+	Convey("Should run dependencies first", t, func() {
+		var mu sync.Mutex
+		order := []string{}
+		run := func(n string) func() {
+			return func() {
+				mu.Lock()
+				order = append(order, n)
+				mu.Unlock()
+			}
+		}
+		ta := NewTasker()
+		ta.Jobs = 4
+		ta.Task("a", run("a"))
+		ta.Task("b", "a", run("b"))
+		ta.Task("c", "b", run("c"))
+		_, err := ta.RunTasks("c")
+		So(err, ShouldBeNil)
+		So(order, ShouldResemble, []string{"a", "b", "c"})
+	})
+
+	Convey("Should not start tasks after an error", t, func() {
+		ran := false
+		ta := NewTasker()
+		ta.Jobs = 2
+		ta.Task("a", func() error { return errors.New("foo") })
+		ta.Task("b", "a", func() { ran = true })
+		rs, err := ta.RunTasks("b")
+		So(err, ShouldNotBeNil)
+		So(ran, ShouldBeFalse)
+		So(rs[0].Status, ShouldEqual, TaskFailed)
+		So(rs[1].Status, ShouldEqual, TaskSkipped)
+	})
+}
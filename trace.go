@@ -0,0 +1,319 @@
+package muta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leeola/muta/logging"
+)
+
+// The FileInfo Ctx key used to associate a file with its FileTrace.
+const traceCtxKey string = "muta.trace"
+
+// NewTracer returns an empty Tracer, ready to be given to Stream.Trace()
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// A Tracer records the path of every file through a traced Stream. See
+// Stream.Trace() for details.
+type Tracer struct {
+	mu    sync.Mutex
+	files []*fileTrace
+}
+
+// FileTrace is the recorded path of a single file through a Stream.
+type FileTrace struct {
+	Steps []TraceStep `json:"steps"`
+}
+
+// TraceStep is a single Streamer that a file visited.
+type TraceStep struct {
+	// The name of the Streamer, as returned by Describe()
+	Streamer string `json:"streamer"`
+
+	// The Name and Path of the file before and after the Streamer was
+	// called. Files created by the Streamer have an empty Name and Path
+	// before.
+	NameBefore string `json:"name_before"`
+	PathBefore string `json:"path_before"`
+	NameAfter  string `json:"name_after"`
+	PathAfter  string `json:"path_after"`
+
+	// The number of bytes the Streamer read from the incoming file, and
+	// the number of bytes that were read from the returned file.
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+
+	// The time spent in the Next() call of this Streamer.
+	Duration time.Duration `json:"duration"`
+
+	// Dropped is true if the Streamer returned a nil FileInfo.
+	Dropped bool `json:"dropped"`
+
+	// The error returned by the Streamer, if any.
+	Error string `json:"error,omitempty"`
+}
+
+type fileTrace struct {
+	steps []*traceStep
+}
+
+type traceStep struct {
+	TraceStep
+	in  *countingReadCloser
+	out *countingReadCloser
+}
+
+func (t *Tracer) newFile(steps []*traceStep) *fileTrace {
+	ft := &fileTrace{steps: steps}
+	t.mu.Lock()
+	t.files = append(t.files, ft)
+	t.mu.Unlock()
+	return ft
+}
+
+// Traces returns the FileTrace of every file seen so far.
+func (t *Tracer) Traces() []FileTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fts := make([]FileTrace, len(t.files))
+	for i, ft := range t.files {
+		fts[i].Steps = make([]TraceStep, len(ft.steps))
+		for j, st := range ft.steps {
+			fts[i].Steps[j] = st.TraceStep
+			if st.in != nil {
+				fts[i].Steps[j].BytesIn = st.in.n
+			}
+			if st.out != nil {
+				fts[i].Steps[j].BytesOut = st.out.n
+			}
+		}
+	}
+	return fts
+}
+
+// WriteJSON writes all of the FileTraces as a JSON array.
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t.Traces())
+}
+
+// WriteText writes all of the FileTraces as human readable tables.
+func (t *Tracer) WriteText(w io.Writer) error {
+	for _, ft := range t.Traces() {
+		if len(ft.Steps) == 0 {
+			continue
+		}
+		last := ft.Steps[len(ft.Steps)-1]
+		fmt.Fprintln(w, filepath.Join(last.PathAfter, last.NameAfter))
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, st := range ft.Steps {
+			before := filepath.Join(st.PathBefore, st.NameBefore)
+			if st.NameBefore == "" {
+				before = "-"
+			}
+			after := filepath.Join(st.PathAfter, st.NameAfter)
+			switch {
+			case st.Error != "":
+				after = "error: " + st.Error
+			case st.Dropped:
+				after = "dropped"
+			}
+			fmt.Fprintf(tw, "  %s\t%s -> %s\t%dB -> %dB\t%s\n", st.Streamer,
+				before, after, st.BytesIn, st.BytesOut, st.Duration)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Trace returns a copy of this Stream which records, for every file,
+// each Streamer it visited, the Name and Path before and after, the
+// bytes in and out, the duration and whether it was dropped.
+//
+// Files are followed through the Stream with the `muta.trace` FileInfo
+// Ctx key, so Streamers that replace a FileInfo should expect the trace
+// to continue on the new FileInfo.
+func (s Stream) Trace(t *Tracer) Stream {
+	return s.Wrap(func(sr Streamer) Streamer {
+		return &traceStreamer{Streamer: sr, name: Describe(sr).Name, t: t}
+	})
+}
+
+type traceStreamer struct {
+	Streamer
+	name string
+	t    *Tracer
+}
+
+func (s *traceStreamer) Describe() Description {
+	return Describe(s.Streamer)
+}
+
+// Close closes the wrapped Streamer, if it implements io.Closer.
+func (s *traceStreamer) Close() error {
+	if c, ok := s.Streamer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SetLogger gives the Logger to the wrapped Streamer, if it implements
+// LoggerSetter.
+func (s *traceStreamer) SetLogger(l *logging.Logger) {
+	if ls, ok := s.Streamer.(LoggerSetter); ok {
+		ls.SetLogger(l)
+	}
+}
+
+func (s *traceStreamer) Next(fi FileInfo, rc io.ReadCloser) (FileInfo,
+	io.ReadCloser, error) {
+	return emitOne(s, fi, rc)
+}
+
+func (s *traceStreamer) Emit(fi FileInfo, rc io.ReadCloser,
+	emit EmitFunc) error {
+
+	var parent *fileTrace
+	var base []*traceStep
+	step := traceStep{}
+	step.Streamer = s.name
+	if fi != nil {
+		parent, _ = fi.Ctx(traceCtxKey).(*fileTrace)
+		step.NameBefore = fi.Name()
+		step.PathBefore = fi.Path()
+	}
+	if parent == nil && fi != nil {
+		parent = s.t.newFile(nil)
+		fi.SetCtx(traceCtxKey, parent)
+	}
+	if parent != nil {
+		base = append(base, parent.steps...)
+	}
+	rc, err := bufferInput(s.Streamer, rc)
+	if err != nil {
+		return err
+	}
+	step.in, rc = newCounter(rc, nil)
+
+	// record is called for every file the Streamer returns, appending the
+	// step to the trace of the file and wrapping the returned reader.
+	var outputs int
+	record := func(ofi FileInfo, orc io.ReadCloser, d time.Duration,
+		err error) io.ReadCloser {
+
+		st := step
+		st.Duration = d
+		if err != nil {
+			st.Error = err.Error()
+		}
+		if ofi == nil {
+			st.Dropped = err == nil
+		} else {
+			st.NameAfter = ofi.Name()
+			st.PathAfter = ofi.Path()
+		}
+		st.out, orc = newCounter(orc, nil)
+
+		// The first returned file continues the incoming trace, any other
+		// files branch off into their own trace.
+		ft := parent
+		if ft == nil || outputs > 0 {
+			ft = s.t.newFile(append([]*traceStep{}, base...))
+		}
+		ft.steps = append(ft.steps, &st)
+		if ofi != nil {
+			ofi.SetCtx(traceCtxKey, ft)
+		}
+		outputs++
+		return orc
+	}
+
+	start := time.Now()
+
+	if e, ok := s.Streamer.(Emitter); ok {
+		// Time spent in emit is spent in the following Streamers, so it is
+		// removed from the duration of this one.
+		var downstream time.Duration
+		err := e.Emit(fi, rc, func(efi FileInfo, erc io.ReadCloser) error {
+			if efi == nil {
+				return nil
+			}
+			erc = record(efi, erc, time.Since(start)-downstream, nil)
+			emitStart := time.Now()
+			err := emit(efi, erc)
+			downstream += time.Since(emitStart)
+			return err
+		})
+		if outputs == 0 && (fi != nil || err != nil) {
+			record(nil, nil, time.Since(start)-downstream, err)
+		}
+		return err
+	}
+
+	ofi, orc, err := s.Streamer.Next(fi, rc)
+	if ofi == nil && fi == nil && err == nil {
+		// Nothing was generated, so there is nothing to trace.
+		return nil
+	}
+	orc = record(ofi, orc, time.Since(start), err)
+	if err != nil || ofi == nil {
+		return err
+	}
+	return emit(ofi, orc)
+}
+
+// newCounter wraps the given io.ReadCloser in a countingReadCloser,
+// returning both the counter, and the io.ReadCloser to use in place of
+// the original. If the original implements Rewinder, so does the
+// returned io.ReadCloser.
+func newCounter(rc io.ReadCloser, total *int64) (*countingReadCloser,
+	io.ReadCloser) {
+
+	if rc == nil {
+		return nil, nil
+	}
+	c := &countingReadCloser{ReadCloser: rc, total: total}
+	if r, ok := rc.(Rewinder); ok {
+		return c, &countingRewinder{c, r}
+	}
+	return c, c
+}
+
+// countingReadCloser counts the bytes read through it. If total is not
+// nil, the bytes are also atomically added to it.
+type countingReadCloser struct {
+	io.ReadCloser
+	n     int64
+	total *int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	if c.total != nil {
+		atomic.AddInt64(c.total, int64(n))
+	}
+	return n, err
+}
+
+type countingRewinder struct {
+	*countingReadCloser
+	r Rewinder
+}
+
+func (c *countingRewinder) Rewind() error {
+	return c.r.Rewind()
+}
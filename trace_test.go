@@ -0,0 +1,150 @@
+package muta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/leeola/muta/logging"
+	"github.com/leeola/muta/mutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamWrap(t *testing.T) {
+	Convey("Should wrap nested Streamers, not nested Streams", t, func() {
+		wrapped := 0
+		s := Stream{&MockStreamer{}, Stream{&MockStreamer{}}}
+		ws := s.Wrap(func(sr Streamer) Streamer {
+			wrapped++
+			return FuncStreamer(sr.Next)
+		})
+		So(wrapped, ShouldEqual, 2)
+		_, ok := ws[1].(Stream)
+		So(ok, ShouldBeTrue)
+		So(s[0], ShouldHaveSameTypeAs, &MockStreamer{})
+	})
+}
+
+func TestStreamTrace(t *testing.T) {
+	rename := FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+		FileInfo, io.ReadCloser, error) {
+		if fi == nil {
+			return fi, rc, nil
+		}
+		b, _ := ioutil.ReadAll(rc)
+		fi.SetName(fi.Name() + ".up")
+		return fi, mutil.ByteCloser(bytes.ToUpper(b)), nil
+	})
+	drop := FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+		FileInfo, io.ReadCloser, error) {
+		if fi != nil && fi.Name() == "bar.up" {
+			return nil, nil, nil
+		}
+		return fi, rc, nil
+	})
+
+	Convey("Should record every Streamer a file visits", t, func() {
+		tr := NewTracer()
+		s := Stream{
+			&MockStreamer{Files: []string{"foo", "bar"}},
+			rename,
+			drop,
+		}
+		err := s.Trace(tr).Stream()
+		So(err, ShouldBeNil)
+
+		fts := tr.Traces()
+		So(len(fts), ShouldEqual, 2)
+
+		foo := fts[0].Steps
+		So(len(foo), ShouldEqual, 3)
+		So(foo[0].Streamer, ShouldEqual, "*muta.MockStreamer")
+		So(foo[0].NameBefore, ShouldEqual, "")
+		So(foo[0].NameAfter, ShouldEqual, "foo")
+		So(foo[0].BytesOut, ShouldEqual, len("foo content"))
+		So(foo[1].NameBefore, ShouldEqual, "foo")
+		So(foo[1].NameAfter, ShouldEqual, "foo.up")
+		So(foo[1].BytesIn, ShouldEqual, len("foo content"))
+		So(foo[2].Dropped, ShouldBeFalse)
+
+		bar := fts[1].Steps
+		So(len(bar), ShouldEqual, 3)
+		So(bar[2].NameBefore, ShouldEqual, "bar.up")
+		So(bar[2].Dropped, ShouldBeTrue)
+	})
+
+	Convey("Should branch traces for emitted files", t, func() {
+		tr := NewTracer()
+		s := Stream{
+			&MockStreamer{Files: []string{"foo"}},
+			FuncEmitter(func(fi FileInfo, rc io.ReadCloser,
+				emit EmitFunc) error {
+				if fi == nil {
+					return nil
+				}
+				emit(fi, rc)
+				return emit(NewFileInfo("foo.gz"), nil)
+			}),
+		}
+		err := s.Trace(tr).Stream()
+		So(err, ShouldBeNil)
+
+		fts := tr.Traces()
+		So(len(fts), ShouldEqual, 2)
+		So(len(fts[1].Steps), ShouldEqual, 2)
+		So(fts[1].Steps[0].NameAfter, ShouldEqual, "foo")
+		So(fts[1].Steps[1].NameAfter, ShouldEqual, "foo.gz")
+	})
+
+	Convey("Should record errors", t, func() {
+		tr := NewTracer()
+		s := Stream{
+			&MockStreamer{Files: []string{"foo"}},
+			FuncStreamer(func(fi FileInfo, rc io.ReadCloser) (
+				FileInfo, io.ReadCloser, error) {
+				return nil, nil, errors.New("oops")
+			}),
+		}
+		err := s.Trace(tr).Stream()
+		So(err, ShouldNotBeNil)
+		steps := tr.Traces()[0].Steps
+		So(steps[1].Error, ShouldEqual, "oops")
+		So(steps[1].Dropped, ShouldBeFalse)
+	})
+
+	Convey("Should export as JSON", t, func() {
+		tr := NewTracer()
+		Stream{&MockStreamer{Files: []string{"foo"}}}.Trace(tr).Stream()
+		var b bytes.Buffer
+		So(tr.WriteJSON(&b), ShouldBeNil)
+		var fts []FileTrace
+		So(json.Unmarshal(b.Bytes(), &fts), ShouldBeNil)
+		So(fts[0].Steps[0].NameAfter, ShouldEqual, "foo")
+	})
+
+	Convey("Should forward Close and SetLogger", t, func() {
+		c, ls := &closeStreamer{}, &logStreamer{}
+		s := Stream{c, ls}.Trace(NewTracer())
+		So(s.Close(), ShouldBeNil)
+		So(c.closed, ShouldBeTrue)
+		l := logging.NewLogger(ioutil.Discard)
+		s[1].(LoggerSetter).SetLogger(l)
+		So(ls.Logger(), ShouldEqual, l)
+	})
+}
+
+func TestTaskerTracer(t *testing.T) {
+	Convey("Should trace Stream tasks", t, func() {
+		ta := NewTasker()
+		ta.Tracer = NewTracer()
+		ta.Task("a", func() Stream {
+			return Stream{&MockStreamer{Files: []string{"foo"}}}
+		})
+		err := ta.RunTask("a")
+		So(err, ShouldBeNil)
+		So(len(ta.Tracer.Traces()), ShouldEqual, 1)
+	})
+}